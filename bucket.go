@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"log"
+	"regexp"
 
 	"github.com/hashicorp/terraform/helper/schema"
 	"fmt"
@@ -15,10 +17,54 @@ func resourceS3Bucket() *schema.Resource {
 		Update: resourceS3BucketUpdate,
 		Delete: resourceS3BucketDelete,
 
+		Timeouts: defaultResourceTimeouts,
+
+		Importer: &schema.ResourceImporter{
+			State: resourceS3BucketImport,
+		},
+
 		Schema: map[string]*schema.Schema{
 			"bucket": {
 				Type:     schema.TypeString,
 				Required: true,
+				ForceNew: true,
+			},
+			"region": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				Computed:         true,
+				ForceNew:         true,
+				ValidateFunc:     validateBucketRegion,
+				DiffSuppressFunc: suppressEquivalentRegion,
+			},
+			"force_destroy": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			"versioning": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Computed: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"enabled": {
+							Type:     schema.TypeBool,
+							Optional: true,
+							Default:  false,
+						},
+						"mfa_delete": {
+							Type:     schema.TypeBool,
+							Optional: true,
+							Default:  false,
+						},
+						"mfa": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+					},
+				},
 			},
 			"debug": {
 				Type:     schema.TypeBool,
@@ -33,13 +79,26 @@ func resourceS3BucketCreate(d *schema.ResourceData, meta interface{}) error {
 	debug := d.Get("debug").(bool)
 	bucket := d.Get("bucket").(string)
 	region := meta.(*s3Client).region
-	s3_client := meta.(*s3Client).s3Client
+	if v, ok := d.GetOk("region"); ok {
+		region = v.(string)
+	}
+	// minio signs every later request for the bucket for the location it was
+	// created with, and EU is not a region.  Both location constraints create
+	// the bucket in eu-west-1.
+	if region == "EU" {
+		region = "eu-west-1"
+	}
+	s3_client := meta.(*s3Client).clientFor("s3_bucket.create", bucket)
+	ctx, cancel := meta.(*s3Client).operationContext(d, schema.TimeoutCreate)
+	defer cancel()
 
 	if debug {
 		log.Printf("[DEBUG] Creating bucket: [%s] in region: [%s]", bucket, region)
 	}
 
-	err := s3_client.MakeBucket(bucket, region)
+	err := meta.(*s3Client).retry(ctx, "s3_bucket.create", false, func() error {
+		return s3_client.MakeBucket(bucket, region)
+	})
 	if err != nil {
 		log.Printf("[FATAL] Unable to create bucket [%s] in region [%s].  Failed with error: %v", bucket, region, err)
 		return errors.New(fmt.Sprintf("Unable to create bucket [%s] in region [%s].  Failed with error: %v", bucket, region, err))
@@ -47,47 +106,219 @@ func resourceS3BucketCreate(d *schema.ResourceData, meta interface{}) error {
 	if debug {
 		log.Printf("[DEBUG] Created bucket: [%s] in region: [%s]", bucket, region)
 	}
-	return nil
+	d.SetId(bucket)
+
+	if v, ok := d.GetOk("versioning"); ok {
+		if m, ok := v.([]interface{})[0].(map[string]interface{}); ok && (m["enabled"].(bool) || m["mfa_delete"].(bool)) {
+			if err := resourceS3BucketVersioningUpdate(ctx, d, meta); err != nil {
+				return err
+			}
+		}
+	}
+	return resourceS3BucketRead(d, meta)
 }
 
 func resourceS3BucketRead(d *schema.ResourceData, meta interface{}) error {
 	debug := d.Get("debug").(bool)
-	bucket := d.Get("bucket").(string)
+	bucket := d.Id()
 	region := meta.(*s3Client).region
-	s3_client := meta.(*s3Client).s3Client
+	s3_client := meta.(*s3Client).clientFor("s3_bucket.read", bucket)
+	ctx, cancel := meta.(*s3Client).operationContext(d, schema.TimeoutRead)
+	defer cancel()
 	if debug {
 		log.Printf("[DEBUG] Reading bucket [%s] in region [%s]", bucket, region)
 	}
-	found, err := s3_client.BucketExists(bucket)
+	var found bool
+	err := meta.(*s3Client).retry(ctx, "s3_bucket.read", true, func() (err error) {
+		found, err = s3_client.BucketExists(bucket)
+		return err
+	})
+	if err != nil && !isGone(err) {
+		log.Printf("[FATAL] Unable to read bucket [%s].  Error: %v", bucket, err)
+		return errors.New(fmt.Sprintf("Unable to read bucket [%s].  Error: %v", bucket, err))
+	}
 	if !found {
-		return errors.New(fmt.Sprintf("[FATAL] Unable to find bucket [%s] in region [%s].  Error: %v",
-			bucket, region, err))
+		log.Printf("[WARN] Bucket [%s] not found, removing from state", bucket)
+		d.SetId("")
+		return nil
+	}
+
+	var location string
+	err = meta.(*s3Client).retry(ctx, "s3_bucket.read", true, func() (err error) {
+		location, err = s3_client.GetBucketLocation(bucket)
+		return err
+	})
+	if err != nil {
+		log.Printf("[FATAL] Unable to read location of bucket [%s].  Error: %v", bucket, err)
+		return errors.New(fmt.Sprintf("Unable to read location of bucket [%s].  Error: %v", bucket, err))
+	}
+	// A bucket found in another region shows up in the plan as a replacement.
+	// The configured spelling of the same region, e.g. EU, is kept.
+	d.Set("bucket", bucket)
+	if configured := d.Get("region").(string); !equivalentRegions(configured, location) {
+		if len(configured) > 0 {
+			log.Printf("[WARN] Bucket [%s] is in region [%s] instead of [%s]", bucket, location, configured)
+		}
+		d.Set("region", location)
+	}
+
+	// Versioning is only read for buckets that manage it, so buckets without a
+	// versioning block keep working on servers or credentials without access
+	// to it.
+	if _, ok := d.GetOk("versioning"); ok {
+		versioning, err := meta.(*s3Client).bucketVersioning(ctx, bucket)
+		switch {
+		case err == nil:
+			d.Set("versioning", flattenVersioning(versioning, d.Get("versioning.0.mfa").(string)))
+		case isNotImplemented(err):
+			log.Printf("[WARN] S3 server does not support versioning, skipping versioning of bucket [%s]", bucket)
+		case isAccessDenied(err):
+			log.Printf("[WARN] Access denied reading versioning of bucket [%s], skipping it", bucket)
+		default:
+			log.Printf("[FATAL] Unable to read versioning of bucket [%s].  Error: %v", bucket, err)
+			return errors.New(fmt.Sprintf("Unable to read versioning of bucket [%s].  Error: %v", bucket, err))
+		}
+	} else {
+		// Without a value in the state the computed block would show up in
+		// every plan.
+		d.Set("versioning", []interface{}{})
+	}
+
+	if debug {
+		log.Printf("[DEBUG] Read bucket [%s] in region [%s]", bucket, location)
 	}
 	return nil
 }
 
 func resourceS3BucketUpdate(d *schema.ResourceData, meta interface{}) error {
+	ctx, cancel := meta.(*s3Client).operationContext(d, schema.TimeoutUpdate)
+	defer cancel()
+
+	if d.HasChange("versioning") {
+		if err := resourceS3BucketVersioningUpdate(ctx, d, meta); err != nil {
+			return err
+		}
+	}
+	return resourceS3BucketRead(d, meta)
+}
+
+func resourceS3BucketVersioningUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) error {
 	debug := d.Get("debug").(bool)
-	bucket := d.Get("bucket").(string)
-	region := meta.(*s3Client).region
+	bucket := d.Id()
+
+	m := map[string]interface{}{"enabled": false, "mfa_delete": false, "mfa": ""}
+	if v := d.Get("versioning").([]interface{}); len(v) > 0 && v[0] != nil {
+		m = v[0].(map[string]interface{})
+	}
+	versioning := expandVersioning(m, d.HasChange("versioning.0.mfa_delete"))
+	mfa := m["mfa"].(string)
+	if len(versioning.MfaDelete) > 0 && len(mfa) < 1 {
+		return errors.New(fmt.Sprintf("Unable to set MFA delete of bucket [%s].  The serial number and code of the MFA device must be set in mfa", bucket))
+	}
+	if debug {
+		log.Printf("[DEBUG] Setting versioning of bucket [%s] to [%s], MFA delete [%s]", bucket, versioning.Status, versioning.MfaDelete)
+	}
+
+	err := meta.(*s3Client).putBucketVersioning(ctx, bucket, versioning, mfa)
+	if err != nil {
+		if isNotImplemented(err) {
+			return errors.New(fmt.Sprintf("Unable to set versioning of bucket [%s].  The S3 server does not support bucket versioning", bucket))
+		}
+		log.Printf("[FATAL] Unable to set versioning of bucket [%s].  Error: %v", bucket, err)
+		return errors.New(fmt.Sprintf("Unable to set versioning of bucket [%s].  Error: %v", bucket, err))
+	}
+
 	if debug {
-		log.Printf("[DEBUG] Bucket update operation not implemented. Bucket: [%s], Region: [%s]",
-			bucket, region)
+		log.Printf("[DEBUG] Set versioning of bucket [%s]", bucket)
 	}
 	return nil
 }
 
 func resourceS3BucketDelete(d *schema.ResourceData, meta interface{}) error {
 	debug := d.Get("debug").(bool)
-	bucket := d.Get("bucket").(string)
+	bucket := d.Id()
 	region := meta.(*s3Client).region
-	s3_client := meta.(*s3Client).s3Client
+	s3_client := meta.(*s3Client).clientFor("s3_bucket.delete", bucket)
+	ctx, cancel := meta.(*s3Client).operationContext(d, schema.TimeoutDelete)
+	defer cancel()
 	if debug {
 		log.Printf("[DEBUG] Deleting bucket [%s] from region [%s]", bucket, region)
 	}
-	if s3_client.RemoveBucket(bucket) != nil {
-		log.Printf("[FATAL]  Unable to remove bucket [%s].")
-		return errors.New(fmt.Sprintf("[FATAL] Unable to remove bucket [%s]", bucket))
+	if d.Get("force_destroy").(bool) {
+		log.Printf("[INFO] Emptying bucket [%s] before deletion", bucket)
+		if err := emptyBucket(ctx, meta.(*s3Client), s3_client, bucket); err != nil {
+			log.Printf("[FATAL] Unable to empty bucket [%s].  Error: %v", bucket, err)
+			return errors.New(fmt.Sprintf("Unable to empty bucket [%s].  Error: %v", bucket, err))
+		}
+	}
+	err := meta.(*s3Client).retry(ctx, "s3_bucket.delete", true, func() error {
+		return s3_client.RemoveBucket(bucket)
+	})
+	if err != nil {
+		if isGone(err) {
+			log.Printf("[WARN] Bucket [%s] already removed", bucket)
+			return nil
+		}
+		log.Printf("[FATAL]  Unable to remove bucket [%s].  Error: %v", bucket, err)
+		return errors.New(fmt.Sprintf("[FATAL] Unable to remove bucket [%s].  Error: %v", bucket, err))
 	}
 	return nil
 }
+
+func resourceS3BucketImport(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	bucket := d.Id()
+	s3_client := meta.(*s3Client).clientFor("s3_bucket.import", bucket)
+	ctx, cancel := meta.(*s3Client).operationContext(d, schema.TimeoutRead)
+	defer cancel()
+
+	var found bool
+	err := meta.(*s3Client).retry(ctx, "s3_bucket.import", true, func() (err error) {
+		found, err = s3_client.BucketExists(bucket)
+		return err
+	})
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Unable to import bucket [%s].  Error: %v", bucket, err))
+	}
+	if !found {
+		return nil, errors.New(fmt.Sprintf("Unable to import bucket [%s].  Bucket not found", bucket))
+	}
+
+	d.Set("bucket", bucket)
+	d.Set("force_destroy", false)
+	d.Set("debug", false)
+	return []*schema.ResourceData{d}, nil
+}
+
+// Location constraints are lower case words separated by hyphens, e.g.
+// us-west-2 or a custom region name on a Minio or Ceph server.
+var bucketRegionRegexp = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// equivalentRegions reports whether two location constraints name the same
+// region.  minio-go reports buckets created in the legacy EU location as
+// eu-west-1.
+func equivalentRegions(a, b string) bool {
+	if a == "EU" {
+		a = "eu-west-1"
+	}
+	if b == "EU" {
+		b = "eu-west-1"
+	}
+	return a == b
+}
+
+func suppressEquivalentRegion(k, old, new string, d *schema.ResourceData) bool {
+	return equivalentRegions(old, new)
+}
+
+func validateBucketRegion(v interface{}, k string) (ws []string, errors []error) {
+	region := v.(string)
+	if len(region) > 63 {
+		errors = append(errors, fmt.Errorf("%q must be at most 63 characters long, got: %s", k, region))
+	}
+	if region == "EU" {
+		ws = append(ws, fmt.Sprintf("%q: EU is a legacy location constraint, use eu-west-1 instead", k))
+	} else if !bucketRegionRegexp.MatchString(region) {
+		errors = append(errors, fmt.Errorf("%q must only contain lower case letters, digits and single hyphens, got: %q", k, region))
+	}
+	return
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/minio/minio-go"
+)
+
+type corsConfiguration struct {
+	XMLName xml.Name   `xml:"CORSConfiguration"`
+	Xmlns   string     `xml:"xmlns,attr,omitempty"`
+	Rules   []corsRule `xml:"CORSRule"`
+}
+
+type corsRule struct {
+	AllowedOrigins []string `xml:"AllowedOrigin"`
+	AllowedMethods []string `xml:"AllowedMethod"`
+	AllowedHeaders []string `xml:"AllowedHeader,omitempty"`
+	ExposeHeaders  []string `xml:"ExposeHeader,omitempty"`
+	MaxAgeSeconds  int      `xml:"MaxAgeSeconds,omitempty"`
+}
+
+func resourceS3BucketCors() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceS3BucketCorsCreate,
+		Read:   resourceS3BucketCorsRead,
+		Update: resourceS3BucketCorsUpdate,
+		Delete: resourceS3BucketCorsDelete,
+
+		Timeouts: defaultResourceTimeouts,
+
+		Importer: &schema.ResourceImporter{
+			State: resourceS3BucketCorsImport,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"bucket": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"cors_rule": {
+				Type:     schema.TypeSet,
+				Required: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"allowed_origins": {
+							Type:     schema.TypeSet,
+							Required: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+						},
+						"allowed_methods": {
+							Type:     schema.TypeSet,
+							Required: true,
+							Elem: &schema.Schema{
+								Type:         schema.TypeString,
+								ValidateFunc: validateCorsMethod,
+							},
+						},
+						"allowed_headers": {
+							Type:     schema.TypeSet,
+							Optional: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+						},
+						"expose_headers": {
+							Type:     schema.TypeSet,
+							Optional: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+						},
+						"max_age_seconds": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							ValidateFunc: validateNonNegative,
+						},
+					},
+				},
+			},
+			"debug": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+		},
+	}
+}
+
+func resourceS3BucketCorsCreate(d *schema.ResourceData, meta interface{}) error {
+	if err := resourceS3BucketCorsPut(d, meta, schema.TimeoutCreate); err != nil {
+		return err
+	}
+	d.SetId(d.Get("bucket").(string))
+	return resourceS3BucketCorsRead(d, meta)
+}
+
+func resourceS3BucketCorsUpdate(d *schema.ResourceData, meta interface{}) error {
+	if err := resourceS3BucketCorsPut(d, meta, schema.TimeoutUpdate); err != nil {
+		return err
+	}
+	return resourceS3BucketCorsRead(d, meta)
+}
+
+func resourceS3BucketCorsPut(d *schema.ResourceData, meta interface{}, timeout string) error {
+	debug := d.Get("debug").(bool)
+	bucket := d.Get("bucket").(string)
+	ctx, cancel := meta.(*s3Client).operationContext(d, timeout)
+	defer cancel()
+
+	config := corsConfiguration{Xmlns: s3XMLNamespace}
+	for _, raw := range d.Get("cors_rule").(*schema.Set).List() {
+		m := raw.(map[string]interface{})
+		config.Rules = append(config.Rules, corsRule{
+			AllowedOrigins: sortedStrings(m["allowed_origins"].(*schema.Set)),
+			AllowedMethods: sortedStrings(m["allowed_methods"].(*schema.Set)),
+			AllowedHeaders: sortedStrings(m["allowed_headers"].(*schema.Set)),
+			ExposeHeaders:  sortedStrings(m["expose_headers"].(*schema.Set)),
+			MaxAgeSeconds:  m["max_age_seconds"].(int),
+		})
+	}
+	body, err := xml.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	if debug {
+		log.Printf("[DEBUG] Setting CORS of bucket [%s]: %s", bucket, body)
+	}
+
+	err = meta.(*s3Client).retry(ctx, "s3_bucket_cors."+timeout, true, func() error {
+		_, err := meta.(*s3Client).bucketRequest(ctx, "s3_bucket_cors."+timeout, "PUT", bucket, url.Values{"cors": {""}}, body)
+		return err
+	})
+	if err != nil {
+		if isNotImplemented(err) {
+			return errors.New(fmt.Sprintf("Unable to set CORS of bucket [%s].  The S3 server does not support CORS configuration", bucket))
+		}
+		log.Printf("[FATAL] Unable to set CORS of bucket [%s].  Error: %v", bucket, err)
+		return errors.New(fmt.Sprintf("Unable to set CORS of bucket [%s].  Error: %v", bucket, err))
+	}
+
+	if debug {
+		log.Printf("[DEBUG] Set CORS of bucket [%s]", bucket)
+	}
+	return nil
+}
+
+func resourceS3BucketCorsRead(d *schema.ResourceData, meta interface{}) error {
+	debug := d.Get("debug").(bool)
+	bucket := d.Get("bucket").(string)
+	ctx, cancel := meta.(*s3Client).operationContext(d, schema.TimeoutRead)
+	defer cancel()
+
+	if debug {
+		log.Printf("[DEBUG] Reading CORS of bucket [%s]", bucket)
+	}
+
+	var data []byte
+	err := meta.(*s3Client).retry(ctx, "s3_bucket_cors.read", true, func() (err error) {
+		data, err = meta.(*s3Client).bucketRequest(ctx, "s3_bucket_cors.read", "GET", bucket, url.Values{"cors": {""}}, nil)
+		return err
+	})
+	if err != nil {
+		if isGone(err) || minio.ToErrorResponse(err).Code == "NoSuchCORSConfiguration" {
+			log.Printf("[WARN] CORS of bucket [%s] not found, removing from state", bucket)
+			d.SetId("")
+			return nil
+		}
+		log.Printf("[FATAL] Unable to read CORS of bucket [%s].  Error: %v", bucket, err)
+		return errors.New(fmt.Sprintf("Unable to read CORS of bucket [%s].  Error: %v", bucket, err))
+	}
+
+	var config corsConfiguration
+	if err := xml.Unmarshal(data, &config); err != nil {
+		return errors.New(fmt.Sprintf("Unable to parse CORS of bucket [%s].  Error: %v", bucket, err))
+	}
+	rules := make([]interface{}, 0, len(config.Rules))
+	for _, rule := range config.Rules {
+		rules = append(rules, map[string]interface{}{
+			"allowed_origins": schema.NewSet(schema.HashString, stringsToInterfaces(rule.AllowedOrigins)),
+			"allowed_methods": schema.NewSet(schema.HashString, stringsToInterfaces(rule.AllowedMethods)),
+			"allowed_headers": schema.NewSet(schema.HashString, stringsToInterfaces(rule.AllowedHeaders)),
+			"expose_headers":  schema.NewSet(schema.HashString, stringsToInterfaces(rule.ExposeHeaders)),
+			"max_age_seconds": rule.MaxAgeSeconds,
+		})
+	}
+	if err := d.Set("cors_rule", rules); err != nil {
+		return errors.New(fmt.Sprintf("Unable to read CORS of bucket [%s].  Error: %v", bucket, err))
+	}
+
+	if debug {
+		log.Printf("[DEBUG] Read %d CORS rules of bucket [%s]", len(rules), bucket)
+	}
+	return nil
+}
+
+func resourceS3BucketCorsDelete(d *schema.ResourceData, meta interface{}) error {
+	debug := d.Get("debug").(bool)
+	bucket := d.Get("bucket").(string)
+	ctx, cancel := meta.(*s3Client).operationContext(d, schema.TimeoutDelete)
+	defer cancel()
+
+	if debug {
+		log.Printf("[DEBUG] Removing CORS of bucket [%s]", bucket)
+	}
+
+	err := meta.(*s3Client).retry(ctx, "s3_bucket_cors.delete", true, func() error {
+		_, err := meta.(*s3Client).bucketRequest(ctx, "s3_bucket_cors.delete", "DELETE", bucket, url.Values{"cors": {""}}, nil)
+		return err
+	})
+	if err != nil && !isGone(err) && minio.ToErrorResponse(err).Code != "NoSuchCORSConfiguration" {
+		log.Printf("[FATAL] Unable to remove CORS of bucket [%s].  Error: %v", bucket, err)
+		return errors.New(fmt.Sprintf("Unable to remove CORS of bucket [%s].  Error: %v", bucket, err))
+	}
+
+	if debug {
+		log.Printf("[DEBUG] Removed CORS of bucket [%s]", bucket)
+	}
+	return nil
+}
+
+func resourceS3BucketCorsImport(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	d.Set("bucket", d.Id())
+	d.Set("debug", false)
+	return []*schema.ResourceData{d}, nil
+}
+
+func validateCorsMethod(v interface{}, k string) (ws []string, errors []error) {
+	switch v.(string) {
+	case "GET", "PUT", "POST", "DELETE", "HEAD":
+	default:
+		errors = append(errors, fmt.Errorf("%q must be one of GET, PUT, POST, DELETE or HEAD, got: %s", k, v.(string)))
+	}
+	return
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResourceS3BucketCors(t *testing.T) {
+	s := newFakeS3(t)
+	s.putBucket("my-bucket", "")
+	meta := s.meta(t, nil)
+	r := resourceS3BucketCors()
+
+	// Create
+	raw := map[string]interface{}{
+		"bucket": "my-bucket",
+		"cors_rule": []interface{}{map[string]interface{}{
+			"allowed_origins": []interface{}{"https://example.com"},
+			"allowed_methods": []interface{}{"PUT", "GET"},
+			"max_age_seconds": 3000,
+		}},
+	}
+	state := testApply(t, r, nil, raw, meta)
+	testCheckAttributes(t, state, map[string]string{"id": "my-bucket", "cors_rule.#": "1"})
+	if !strings.Contains(string(s.subresource("my-bucket", "cors")), "<AllowedOrigin>https://example.com</AllowedOrigin>") {
+		t.Fatalf("expected the CORS rule to be set, got: %s", s.subresource("my-bucket", "cors"))
+	}
+	testCheckNoPlan(t, r, state, raw, meta)
+
+	// Update
+	raw["cors_rule"] = append(raw["cors_rule"].([]interface{}), map[string]interface{}{
+		"allowed_origins": []interface{}{"*"},
+		"allowed_methods": []interface{}{"HEAD"},
+	})
+	state = testApply(t, r, state, raw, meta)
+	testCheckAttributes(t, state, map[string]string{"cors_rule.#": "2"})
+	testCheckNoPlan(t, r, state, raw, meta)
+
+	// Import
+	imported := testImport(t, r, "my-bucket", meta)
+	testCheckAttributes(t, imported, map[string]string{"bucket": "my-bucket", "cors_rule.#": "2"})
+
+	// Destroy
+	testDestroy(t, r, state, meta)
+	if s.subresource("my-bucket", "cors") != nil {
+		t.Fatal("expected the CORS configuration to be removed")
+	}
+	if state := testRefresh(t, r, state, meta); state != nil {
+		t.Fatalf("expected the removed CORS configuration to leave the state, got: %v", state)
+	}
+}
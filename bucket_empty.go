@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/minio/minio-go"
+)
+
+// Progress of force_destroy is logged every emptyBucketLogInterval objects.
+const emptyBucketLogInterval = 1000
+
+type objectVersion struct {
+	Key       string `xml:"Key"`
+	VersionId string `xml:"VersionId,omitempty"`
+}
+
+type listVersionsResult struct {
+	IsTruncated         bool            `xml:"IsTruncated"`
+	NextKeyMarker       string          `xml:"NextKeyMarker"`
+	NextVersionIdMarker string          `xml:"NextVersionIdMarker"`
+	Versions            []objectVersion `xml:"Version"`
+	DeleteMarkers       []objectVersion `xml:"DeleteMarker"`
+}
+
+type deleteObjectsRequest struct {
+	XMLName xml.Name        `xml:"Delete"`
+	Quiet   bool            `xml:"Quiet"`
+	Objects []objectVersion `xml:"Object"`
+}
+
+type deleteObjectsResult struct {
+	Errors []struct {
+		Key       string `xml:"Key"`
+		VersionId string `xml:"VersionId"`
+		Code      string `xml:"Code"`
+		Message   string `xml:"Message"`
+	} `xml:"Error"`
+}
+
+// emptyBucket removes every object, incomplete multipart upload and, when the
+// bucket is versioned, every object version and delete marker from bucket.
+// Keys that could not be removed are all reported in the returned error.
+func emptyBucket(ctx context.Context, client *s3Client, s3_client *minio.Client, bucket string) error {
+	var failures []string
+
+	// Objects
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+	objectsCh := make(chan string)
+	// listErr and listed belong to the listing goroutine until wg.Wait returns.
+	var listErr error
+	listed := 0
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(objectsCh)
+		for object := range s3_client.ListObjectsV2(bucket, "", true, doneCh) {
+			if object.Err != nil {
+				listErr = object.Err
+				return
+			}
+			select {
+			case objectsCh <- object.Key:
+			case <-ctx.Done():
+				return
+			}
+			listed++
+			if listed%emptyBucketLogInterval == 0 {
+				log.Printf("[INFO] Removing objects from bucket [%s]: %d so far", bucket, listed)
+			}
+		}
+	}()
+	for removeErr := range s3_client.RemoveObjectsWithContext(ctx, bucket, objectsCh) {
+		failures = append(failures, fmt.Sprintf("%s: %v", removeErr.ObjectName, removeErr.Err))
+	}
+	wg.Wait()
+	if listErr != nil && !isGone(listErr) {
+		return errors.New(fmt.Sprintf("Unable to list objects in bucket [%s].  Error: %v", bucket, listErr))
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	log.Printf("[INFO] Removed %d objects from bucket [%s]", listed-len(failures), bucket)
+
+	// Incomplete multipart uploads
+	aborted := 0
+	for upload := range s3_client.ListIncompleteUploads(bucket, "", true, doneCh) {
+		if upload.Err != nil {
+			if isGone(upload.Err) {
+				break
+			}
+			return errors.New(fmt.Sprintf("Unable to list incomplete uploads in bucket [%s].  Error: %v", bucket, upload.Err))
+		}
+		err := client.retry(ctx, "s3_bucket.delete", true, func() error {
+			return s3_client.RemoveIncompleteUpload(bucket, upload.Key)
+		})
+		if err != nil && !isGone(err) {
+			failures = append(failures, fmt.Sprintf("%s (upload %s): %v", upload.Key, upload.UploadID, err))
+			continue
+		}
+		aborted++
+	}
+	if aborted > 0 {
+		log.Printf("[INFO] Aborted %d incomplete uploads in bucket [%s]", aborted, bucket)
+	}
+
+	// Object versions
+	status, err := client.bucketVersioning(ctx, bucket)
+	if err != nil {
+		log.Printf("[WARN] Unable to read versioning of bucket [%s], skipping object versions.  Error: %v", bucket, err)
+	} else if len(status.Status) > 0 {
+		versionFailures, err := client.removeObjectVersions(ctx, bucket)
+		if err != nil {
+			return err
+		}
+		failures = append(failures, versionFailures...)
+	}
+
+	if len(failures) > 0 {
+		return errors.New(fmt.Sprintf("Unable to remove %d keys from bucket [%s]:\n%s",
+			len(failures), bucket, strings.Join(failures, "\n")))
+	}
+	return nil
+}
+
+// removeObjectVersions deletes every object version and delete marker in
+// bucket, one page of the version listing at a time.
+func (c *s3Client) removeObjectVersions(ctx context.Context, bucket string) ([]string, error) {
+	var failures []string
+	removed := 0
+	keyMarker, versionMarker := "", ""
+	for {
+		query := url.Values{"versions": {""}}
+		if len(keyMarker) > 0 {
+			query.Set("key-marker", keyMarker)
+			query.Set("version-id-marker", versionMarker)
+		}
+		var data []byte
+		err := c.retry(ctx, "s3_bucket.delete", true, func() (err error) {
+			data, err = c.bucketRequest(ctx, "s3_bucket.delete", "GET", bucket, query, nil)
+			return err
+		})
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Unable to list object versions in bucket [%s].  Error: %v", bucket, err))
+		}
+		var page listVersionsResult
+		if err := xml.Unmarshal(data, &page); err != nil {
+			return nil, errors.New(fmt.Sprintf("Unable to parse object versions in bucket [%s].  Error: %v", bucket, err))
+		}
+
+		versions := append(page.Versions, page.DeleteMarkers...)
+		if len(versions) > 0 {
+			body, err := xml.Marshal(deleteObjectsRequest{Quiet: true, Objects: versions})
+			if err != nil {
+				return nil, err
+			}
+			err = c.retry(ctx, "s3_bucket.delete", true, func() (err error) {
+				data, err = c.bucketRequest(ctx, "s3_bucket.delete", "POST", bucket, url.Values{"delete": {""}}, body)
+				return err
+			})
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("Unable to remove object versions from bucket [%s].  Error: %v", bucket, err))
+			}
+			var result deleteObjectsResult
+			if err := xml.Unmarshal(data, &result); err != nil {
+				return nil, errors.New(fmt.Sprintf("Unable to parse delete result for bucket [%s].  Error: %v", bucket, err))
+			}
+			for _, e := range result.Errors {
+				failures = append(failures, fmt.Sprintf("%s (version %s): %s: %s", e.Key, e.VersionId, e.Code, e.Message))
+			}
+			removed += len(versions) - len(result.Errors)
+			log.Printf("[INFO] Removing object versions from bucket [%s]: %d so far", bucket, removed)
+		}
+
+		if !page.IsTruncated {
+			break
+		}
+		keyMarker, versionMarker = page.NextKeyMarker, page.NextVersionIdMarker
+	}
+	return failures, nil
+}
@@ -0,0 +1,460 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/minio/minio-go"
+)
+
+// Lifecycle dates are configured as YYYY-MM-DD and sent as midnight UTC.
+const lifecycleDateFormat = "2006-01-02"
+
+type lifecycleConfiguration struct {
+	XMLName xml.Name        `xml:"LifecycleConfiguration"`
+	Xmlns   string          `xml:"xmlns,attr,omitempty"`
+	Rules   []lifecycleRule `xml:"Rule"`
+}
+
+type lifecycleRule struct {
+	ID                             string                             `xml:"ID,omitempty"`
+	Prefix                         *string                            `xml:"Prefix,omitempty"`
+	Filter                         *lifecycleFilter                   `xml:"Filter,omitempty"`
+	Status                         string                             `xml:"Status"`
+	Transitions                    []lifecycleTransition              `xml:"Transition,omitempty"`
+	Expiration                     *lifecycleExpiration               `xml:"Expiration,omitempty"`
+	NoncurrentVersionExpiration    *lifecycleNoncurrentExpiration     `xml:"NoncurrentVersionExpiration,omitempty"`
+	AbortIncompleteMultipartUpload *lifecycleAbortIncompleteMultipart `xml:"AbortIncompleteMultipartUpload,omitempty"`
+}
+
+type lifecycleFilter struct {
+	Prefix *string       `xml:"Prefix,omitempty"`
+	Tag    *lifecycleTag `xml:"Tag,omitempty"`
+	And    *lifecycleAnd `xml:"And,omitempty"`
+}
+
+type lifecycleAnd struct {
+	Prefix string         `xml:"Prefix,omitempty"`
+	Tags   []lifecycleTag `xml:"Tag"`
+}
+
+type lifecycleTag struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+type lifecycleTransition struct {
+	Days         int    `xml:"Days,omitempty"`
+	Date         string `xml:"Date,omitempty"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type lifecycleExpiration struct {
+	Days                      int    `xml:"Days,omitempty"`
+	Date                      string `xml:"Date,omitempty"`
+	ExpiredObjectDeleteMarker bool   `xml:"ExpiredObjectDeleteMarker,omitempty"`
+}
+
+type lifecycleNoncurrentExpiration struct {
+	NoncurrentDays int `xml:"NoncurrentDays"`
+}
+
+type lifecycleAbortIncompleteMultipart struct {
+	DaysAfterInitiation int `xml:"DaysAfterInitiation"`
+}
+
+func resourceS3BucketLifecycle() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceS3BucketLifecycleCreate,
+		Read:   resourceS3BucketLifecycleRead,
+		Update: resourceS3BucketLifecycleUpdate,
+		Delete: resourceS3BucketLifecycleDelete,
+
+		Timeouts: defaultResourceTimeouts,
+
+		Importer: &schema.ResourceImporter{
+			State: resourceS3BucketLifecycleImport,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"bucket": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"rule": {
+				Type:     schema.TypeList,
+				Required: true,
+				MinItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"id": {
+							Type:     schema.TypeString,
+							Optional: true,
+							Computed: true,
+						},
+						"enabled": {
+							Type:     schema.TypeBool,
+							Optional: true,
+							Default:  true,
+						},
+						"prefix": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"tags": {
+							Type:     schema.TypeMap,
+							Optional: true,
+						},
+						"expiration": {
+							Type:     schema.TypeList,
+							Optional: true,
+							MaxItems: 1,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"days": {
+										Type:         schema.TypeInt,
+										Optional:     true,
+										ValidateFunc: validateNonNegative,
+									},
+									"date": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										ValidateFunc: validateLifecycleDate,
+									},
+									"expired_object_delete_marker": {
+										Type:     schema.TypeBool,
+										Optional: true,
+									},
+								},
+							},
+						},
+						"transition": {
+							Type:     schema.TypeList,
+							Optional: true,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"days": {
+										Type:         schema.TypeInt,
+										Optional:     true,
+										ValidateFunc: validateNonNegative,
+									},
+									"date": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										ValidateFunc: validateLifecycleDate,
+									},
+									"storage_class": {
+										Type:     schema.TypeString,
+										Required: true,
+									},
+								},
+							},
+						},
+						"noncurrent_version_expiration_days": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							ValidateFunc: validateNonNegative,
+						},
+						"abort_incomplete_multipart_upload_days": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							ValidateFunc: validateNonNegative,
+						},
+					},
+				},
+			},
+			"debug": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+		},
+	}
+}
+
+func resourceS3BucketLifecycleCreate(d *schema.ResourceData, meta interface{}) error {
+	if err := resourceS3BucketLifecyclePut(d, meta, schema.TimeoutCreate); err != nil {
+		return err
+	}
+	d.SetId(d.Get("bucket").(string))
+	return resourceS3BucketLifecycleRead(d, meta)
+}
+
+func resourceS3BucketLifecycleUpdate(d *schema.ResourceData, meta interface{}) error {
+	if err := resourceS3BucketLifecyclePut(d, meta, schema.TimeoutUpdate); err != nil {
+		return err
+	}
+	return resourceS3BucketLifecycleRead(d, meta)
+}
+
+func resourceS3BucketLifecyclePut(d *schema.ResourceData, meta interface{}, timeout string) error {
+	debug := d.Get("debug").(bool)
+	bucket := d.Get("bucket").(string)
+	ctx, cancel := meta.(*s3Client).operationContext(d, timeout)
+	defer cancel()
+
+	config := lifecycleConfiguration{Xmlns: s3XMLNamespace}
+	for i, raw := range d.Get("rule").([]interface{}) {
+		rule, err := expandLifecycleRule(raw.(map[string]interface{}))
+		if err != nil {
+			return errors.New(fmt.Sprintf("Invalid lifecycle rule %d for bucket [%s].  Error: %v", i, bucket, err))
+		}
+		config.Rules = append(config.Rules, rule)
+	}
+	body, err := xml.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	if debug {
+		log.Printf("[DEBUG] Setting lifecycle of bucket [%s]: %s", bucket, body)
+	}
+
+	err = meta.(*s3Client).retry(ctx, "s3_bucket_lifecycle."+timeout, true, func() error {
+		_, err := meta.(*s3Client).bucketRequest(ctx, "s3_bucket_lifecycle."+timeout, "PUT", bucket, url.Values{"lifecycle": {""}}, body)
+		return err
+	})
+	if err != nil {
+		if isNotImplemented(err) {
+			return errors.New(fmt.Sprintf("Unable to set lifecycle of bucket [%s].  The S3 server does not support lifecycle rules", bucket))
+		}
+		log.Printf("[FATAL] Unable to set lifecycle of bucket [%s].  Error: %v", bucket, err)
+		return errors.New(fmt.Sprintf("Unable to set lifecycle of bucket [%s].  Error: %v", bucket, err))
+	}
+
+	if debug {
+		log.Printf("[DEBUG] Set lifecycle of bucket [%s]", bucket)
+	}
+	return nil
+}
+
+func resourceS3BucketLifecycleRead(d *schema.ResourceData, meta interface{}) error {
+	debug := d.Get("debug").(bool)
+	bucket := d.Get("bucket").(string)
+	ctx, cancel := meta.(*s3Client).operationContext(d, schema.TimeoutRead)
+	defer cancel()
+
+	if debug {
+		log.Printf("[DEBUG] Reading lifecycle of bucket [%s]", bucket)
+	}
+
+	var data []byte
+	err := meta.(*s3Client).retry(ctx, "s3_bucket_lifecycle.read", true, func() (err error) {
+		data, err = meta.(*s3Client).bucketRequest(ctx, "s3_bucket_lifecycle.read", "GET", bucket, url.Values{"lifecycle": {""}}, nil)
+		return err
+	})
+	if err != nil {
+		if isGone(err) || minio.ToErrorResponse(err).Code == "NoSuchLifecycleConfiguration" {
+			log.Printf("[WARN] Lifecycle of bucket [%s] not found, removing from state", bucket)
+			d.SetId("")
+			return nil
+		}
+		log.Printf("[FATAL] Unable to read lifecycle of bucket [%s].  Error: %v", bucket, err)
+		return errors.New(fmt.Sprintf("Unable to read lifecycle of bucket [%s].  Error: %v", bucket, err))
+	}
+
+	var config lifecycleConfiguration
+	if err := xml.Unmarshal(data, &config); err != nil {
+		return errors.New(fmt.Sprintf("Unable to parse lifecycle of bucket [%s].  Error: %v", bucket, err))
+	}
+	rules := make([]interface{}, 0, len(config.Rules))
+	for _, rule := range config.Rules {
+		rules = append(rules, flattenLifecycleRule(rule))
+	}
+	if err := d.Set("rule", rules); err != nil {
+		return errors.New(fmt.Sprintf("Unable to read lifecycle of bucket [%s].  Error: %v", bucket, err))
+	}
+
+	if debug {
+		log.Printf("[DEBUG] Read %d lifecycle rules of bucket [%s]", len(rules), bucket)
+	}
+	return nil
+}
+
+func resourceS3BucketLifecycleDelete(d *schema.ResourceData, meta interface{}) error {
+	debug := d.Get("debug").(bool)
+	bucket := d.Get("bucket").(string)
+	ctx, cancel := meta.(*s3Client).operationContext(d, schema.TimeoutDelete)
+	defer cancel()
+
+	if debug {
+		log.Printf("[DEBUG] Removing lifecycle of bucket [%s]", bucket)
+	}
+
+	err := meta.(*s3Client).retry(ctx, "s3_bucket_lifecycle.delete", true, func() error {
+		_, err := meta.(*s3Client).bucketRequest(ctx, "s3_bucket_lifecycle.delete", "DELETE", bucket, url.Values{"lifecycle": {""}}, nil)
+		return err
+	})
+	if err != nil && !isGone(err) && minio.ToErrorResponse(err).Code != "NoSuchLifecycleConfiguration" {
+		log.Printf("[FATAL] Unable to remove lifecycle of bucket [%s].  Error: %v", bucket, err)
+		return errors.New(fmt.Sprintf("Unable to remove lifecycle of bucket [%s].  Error: %v", bucket, err))
+	}
+
+	if debug {
+		log.Printf("[DEBUG] Removed lifecycle of bucket [%s]", bucket)
+	}
+	return nil
+}
+
+func resourceS3BucketLifecycleImport(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	d.Set("bucket", d.Id())
+	d.Set("debug", false)
+	return []*schema.ResourceData{d}, nil
+}
+
+func expandLifecycleRule(m map[string]interface{}) (lifecycleRule, error) {
+	rule := lifecycleRule{
+		ID:     m["id"].(string),
+		Status: "Disabled",
+	}
+	if m["enabled"].(bool) {
+		rule.Status = "Enabled"
+	}
+
+	prefix := m["prefix"].(string)
+	tags := m["tags"].(map[string]interface{})
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	switch {
+	case len(keys) == 0:
+		rule.Filter = &lifecycleFilter{Prefix: &prefix}
+	case len(keys) == 1 && len(prefix) == 0:
+		rule.Filter = &lifecycleFilter{Tag: &lifecycleTag{Key: keys[0], Value: tags[keys[0]].(string)}}
+	default:
+		and := &lifecycleAnd{Prefix: prefix}
+		for _, key := range keys {
+			and.Tags = append(and.Tags, lifecycleTag{Key: key, Value: tags[key].(string)})
+		}
+		rule.Filter = &lifecycleFilter{And: and}
+	}
+
+	if v := m["expiration"].([]interface{}); len(v) > 0 && v[0] != nil {
+		e := v[0].(map[string]interface{})
+		days, date := e["days"].(int), e["date"].(string)
+		if days > 0 && len(date) > 0 {
+			return rule, errors.New("expiration can not set both days and date")
+		}
+		rule.Expiration = &lifecycleExpiration{
+			Days:                      days,
+			Date:                      lifecycleDate(date),
+			ExpiredObjectDeleteMarker: e["expired_object_delete_marker"].(bool),
+		}
+	}
+
+	for _, v := range m["transition"].([]interface{}) {
+		t := v.(map[string]interface{})
+		days, date := t["days"].(int), t["date"].(string)
+		if days > 0 && len(date) > 0 {
+			return rule, errors.New("transition can not set both days and date")
+		}
+		rule.Transitions = append(rule.Transitions, lifecycleTransition{
+			Days:         days,
+			Date:         lifecycleDate(date),
+			StorageClass: t["storage_class"].(string),
+		})
+	}
+
+	if days := m["noncurrent_version_expiration_days"].(int); days > 0 {
+		rule.NoncurrentVersionExpiration = &lifecycleNoncurrentExpiration{NoncurrentDays: days}
+	}
+	if days := m["abort_incomplete_multipart_upload_days"].(int); days > 0 {
+		rule.AbortIncompleteMultipartUpload = &lifecycleAbortIncompleteMultipart{DaysAfterInitiation: days}
+	}
+	return rule, nil
+}
+
+func flattenLifecycleRule(rule lifecycleRule) map[string]interface{} {
+	m := map[string]interface{}{
+		"id":                                     rule.ID,
+		"enabled":                                rule.Status == "Enabled",
+		"prefix":                                 "",
+		"tags":                                   map[string]interface{}{},
+		"expiration":                             []interface{}{},
+		"transition":                             []interface{}{},
+		"noncurrent_version_expiration_days":     0,
+		"abort_incomplete_multipart_upload_days": 0,
+	}
+
+	// Rules written before filters existed carry the prefix on the rule.
+	if rule.Prefix != nil {
+		m["prefix"] = *rule.Prefix
+	}
+	if f := rule.Filter; f != nil {
+		tags := map[string]interface{}{}
+		if f.Prefix != nil {
+			m["prefix"] = *f.Prefix
+		}
+		if f.Tag != nil {
+			tags[f.Tag.Key] = f.Tag.Value
+		}
+		if f.And != nil {
+			m["prefix"] = f.And.Prefix
+			for _, tag := range f.And.Tags {
+				tags[tag.Key] = tag.Value
+			}
+		}
+		m["tags"] = tags
+	}
+
+	if e := rule.Expiration; e != nil {
+		m["expiration"] = []interface{}{map[string]interface{}{
+			"days":                         e.Days,
+			"date":                         flattenLifecycleDate(e.Date),
+			"expired_object_delete_marker": e.ExpiredObjectDeleteMarker,
+		}}
+	}
+	transitions := make([]interface{}, 0, len(rule.Transitions))
+	for _, t := range rule.Transitions {
+		transitions = append(transitions, map[string]interface{}{
+			"days":          t.Days,
+			"date":          flattenLifecycleDate(t.Date),
+			"storage_class": t.StorageClass,
+		})
+	}
+	m["transition"] = transitions
+
+	if rule.NoncurrentVersionExpiration != nil {
+		m["noncurrent_version_expiration_days"] = rule.NoncurrentVersionExpiration.NoncurrentDays
+	}
+	if rule.AbortIncompleteMultipartUpload != nil {
+		m["abort_incomplete_multipart_upload_days"] = rule.AbortIncompleteMultipartUpload.DaysAfterInitiation
+	}
+	return m
+}
+
+// lifecycleDate converts a configured YYYY-MM-DD date to the ISO 8601 form
+// required by S3.
+func lifecycleDate(date string) string {
+	if len(date) < 1 {
+		return ""
+	}
+	return date + "T00:00:00Z"
+}
+
+func flattenLifecycleDate(date string) string {
+	if len(date) < 1 {
+		return ""
+	}
+	t, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		return date
+	}
+	return t.UTC().Format(lifecycleDateFormat)
+}
+
+func validateLifecycleDate(v interface{}, k string) (ws []string, errors []error) {
+	if _, err := time.Parse(lifecycleDateFormat, v.(string)); err != nil {
+		errors = append(errors, fmt.Errorf("%q must be a date in YYYY-MM-DD format, got: %s", k, v.(string)))
+	}
+	return
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResourceS3BucketLifecycle(t *testing.T) {
+	s := newFakeS3(t)
+	s.putBucket("my-bucket", "")
+	meta := s.meta(t, nil)
+	r := resourceS3BucketLifecycle()
+
+	// Create
+	raw := map[string]interface{}{
+		"bucket": "my-bucket",
+		"rule": []interface{}{map[string]interface{}{
+			"id":         "expire-logs",
+			"prefix":     "logs/",
+			"expiration": []interface{}{map[string]interface{}{"days": 30}},
+		}},
+	}
+	state := testApply(t, r, nil, raw, meta)
+	testCheckAttributes(t, state, map[string]string{
+		"id":                       "my-bucket",
+		"rule.0.id":                "expire-logs",
+		"rule.0.expiration.0.days": "30",
+		"rule.0.enabled":           "true",
+	})
+	testCheckNoPlan(t, r, state, raw, meta)
+
+	// Update
+	raw["rule"].([]interface{})[0].(map[string]interface{})["enabled"] = false
+	state = testApply(t, r, state, raw, meta)
+	if !strings.Contains(string(s.subresource("my-bucket", "lifecycle")), "<Status>Disabled</Status>") {
+		t.Fatalf("expected the rule to be disabled, got: %s", s.subresource("my-bucket", "lifecycle"))
+	}
+	testCheckNoPlan(t, r, state, raw, meta)
+
+	// Import
+	imported := testImport(t, r, "my-bucket", meta)
+	testCheckAttributes(t, imported, map[string]string{"bucket": "my-bucket", "rule.#": "1", "rule.0.enabled": "false"})
+
+	// Destroy
+	testDestroy(t, r, state, meta)
+	if s.subresource("my-bucket", "lifecycle") != nil {
+		t.Fatal("expected the lifecycle rules to be removed")
+	}
+	if state := testRefresh(t, r, state, meta); state != nil {
+		t.Fatalf("expected the removed lifecycle rules to leave the state, got: %v", state)
+	}
+}
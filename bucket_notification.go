@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/hashicorp/terraform/helper/hashcode"
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/minio/minio-go"
+)
+
+// Event types accepted in notification configurations.
+var knownNotificationEvents = map[string]bool{
+	string(minio.ObjectCreatedAll):             true,
+	minio.ObjectCreatedPut:                     true,
+	minio.ObjectCreatedPost:                    true,
+	minio.ObjectCreatedCopy:                    true,
+	minio.ObjectCreatedCompleteMultipartUpload: true,
+	minio.ObjectAccessedGet:                    true,
+	minio.ObjectAccessedHead:                   true,
+	minio.ObjectAccessedAll:                    true,
+	minio.ObjectRemovedAll:                     true,
+	minio.ObjectRemovedDelete:                  true,
+	minio.ObjectRemovedDeleteMarkerCreated:     true,
+	minio.ObjectReducedRedundancyLostObject:    true,
+}
+
+func resourceS3BucketNotification() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceS3BucketNotificationCreate,
+		Read:   resourceS3BucketNotificationRead,
+		Update: resourceS3BucketNotificationUpdate,
+		Delete: resourceS3BucketNotificationDelete,
+
+		Timeouts: defaultResourceTimeouts,
+
+		Importer: &schema.ResourceImporter{
+			State: resourceS3BucketNotificationImport,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"bucket": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"queue":  notificationTargetSchema(),
+			"topic":  notificationTargetSchema(),
+			"lambda": notificationTargetSchema(),
+			"debug": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+		},
+	}
+}
+
+func notificationTargetSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:     schema.TypeSet,
+		Optional: true,
+		Set:      notificationTargetHash,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"arn": {
+					Type:     schema.TypeString,
+					Required: true,
+				},
+				"events": {
+					Type:     schema.TypeSet,
+					Required: true,
+					Elem: &schema.Schema{
+						Type:         schema.TypeString,
+						ValidateFunc: validateNotificationEvent,
+					},
+				},
+				"filter_prefix": {
+					Type:     schema.TypeString,
+					Optional: true,
+				},
+				"filter_suffix": {
+					Type:     schema.TypeString,
+					Optional: true,
+				},
+			},
+		},
+	}
+}
+
+// notificationTargetHash hashes a queue, topic or lambda block so that neither
+// the order of the blocks nor the order of their events causes a diff.
+func notificationTargetHash(v interface{}) int {
+	var buf bytes.Buffer
+	m := v.(map[string]interface{})
+	buf.WriteString(fmt.Sprintf("%s-", m["arn"].(string)))
+	for _, event := range sortedStrings(m["events"].(*schema.Set)) {
+		buf.WriteString(fmt.Sprintf("%s-", event))
+	}
+	if prefix, ok := m["filter_prefix"]; ok {
+		buf.WriteString(fmt.Sprintf("prefix:%s-", prefix.(string)))
+	}
+	if suffix, ok := m["filter_suffix"]; ok {
+		buf.WriteString(fmt.Sprintf("suffix:%s-", suffix.(string)))
+	}
+	return hashcode.String(buf.String())
+}
+
+func resourceS3BucketNotificationCreate(d *schema.ResourceData, meta interface{}) error {
+	if err := resourceS3BucketNotificationPut(d, meta, schema.TimeoutCreate); err != nil {
+		return err
+	}
+	d.SetId(d.Get("bucket").(string))
+	return resourceS3BucketNotificationRead(d, meta)
+}
+
+func resourceS3BucketNotificationUpdate(d *schema.ResourceData, meta interface{}) error {
+	if err := resourceS3BucketNotificationPut(d, meta, schema.TimeoutUpdate); err != nil {
+		return err
+	}
+	return resourceS3BucketNotificationRead(d, meta)
+}
+
+func resourceS3BucketNotificationPut(d *schema.ResourceData, meta interface{}, timeout string) error {
+	debug := d.Get("debug").(bool)
+	bucket := d.Get("bucket").(string)
+	s3_client := meta.(*s3Client).clientFor("s3_bucket_notification."+timeout, bucket)
+	ctx, cancel := meta.(*s3Client).operationContext(d, timeout)
+	defer cancel()
+
+	notification := minio.BucketNotification{}
+	for _, v := range d.Get("queue").(*schema.Set).List() {
+		config := expandNotificationConfig(v.(map[string]interface{}))
+		notification.QueueConfigs = append(notification.QueueConfigs, minio.QueueConfig{NotificationConfig: config, Queue: v.(map[string]interface{})["arn"].(string)})
+	}
+	for _, v := range d.Get("topic").(*schema.Set).List() {
+		config := expandNotificationConfig(v.(map[string]interface{}))
+		notification.TopicConfigs = append(notification.TopicConfigs, minio.TopicConfig{NotificationConfig: config, Topic: v.(map[string]interface{})["arn"].(string)})
+	}
+	for _, v := range d.Get("lambda").(*schema.Set).List() {
+		config := expandNotificationConfig(v.(map[string]interface{}))
+		notification.LambdaConfigs = append(notification.LambdaConfigs, minio.LambdaConfig{NotificationConfig: config, Lambda: v.(map[string]interface{})["arn"].(string)})
+	}
+
+	if debug {
+		log.Printf("[DEBUG] Setting notifications of bucket [%s]: %d queues, %d topics, %d lambdas", bucket,
+			len(notification.QueueConfigs), len(notification.TopicConfigs), len(notification.LambdaConfigs))
+	}
+
+	err := meta.(*s3Client).retry(ctx, "s3_bucket_notification."+timeout, true, func() error {
+		return s3_client.SetBucketNotification(bucket, notification)
+	})
+	if err != nil {
+		log.Printf("[FATAL] Unable to set notifications of bucket [%s].  Error: %v", bucket, err)
+		return errors.New(fmt.Sprintf("Unable to set notifications of bucket [%s].  Error: %v", bucket, err))
+	}
+
+	if debug {
+		log.Printf("[DEBUG] Set notifications of bucket [%s]", bucket)
+	}
+	return nil
+}
+
+func resourceS3BucketNotificationRead(d *schema.ResourceData, meta interface{}) error {
+	debug := d.Get("debug").(bool)
+	bucket := d.Get("bucket").(string)
+	s3_client := meta.(*s3Client).clientFor("s3_bucket_notification.read", bucket)
+	ctx, cancel := meta.(*s3Client).operationContext(d, schema.TimeoutRead)
+	defer cancel()
+
+	if debug {
+		log.Printf("[DEBUG] Reading notifications of bucket [%s]", bucket)
+	}
+
+	var notification minio.BucketNotification
+	err := meta.(*s3Client).retry(ctx, "s3_bucket_notification.read", true, func() (err error) {
+		notification, err = s3_client.GetBucketNotification(bucket)
+		return err
+	})
+	if err != nil {
+		if isGone(err) {
+			log.Printf("[WARN] Bucket [%s] not found, removing notifications from state", bucket)
+			d.SetId("")
+			return nil
+		}
+		log.Printf("[FATAL] Unable to read notifications of bucket [%s].  Error: %v", bucket, err)
+		return errors.New(fmt.Sprintf("Unable to read notifications of bucket [%s].  Error: %v", bucket, err))
+	}
+
+	queues := make([]interface{}, 0, len(notification.QueueConfigs))
+	for _, q := range notification.QueueConfigs {
+		queues = append(queues, flattenNotificationConfig(q.Queue, q.NotificationConfig))
+	}
+	topics := make([]interface{}, 0, len(notification.TopicConfigs))
+	for _, t := range notification.TopicConfigs {
+		topics = append(topics, flattenNotificationConfig(t.Topic, t.NotificationConfig))
+	}
+	lambdas := make([]interface{}, 0, len(notification.LambdaConfigs))
+	for _, l := range notification.LambdaConfigs {
+		lambdas = append(lambdas, flattenNotificationConfig(l.Lambda, l.NotificationConfig))
+	}
+	d.Set("queue", queues)
+	d.Set("topic", topics)
+	d.Set("lambda", lambdas)
+
+	if debug {
+		log.Printf("[DEBUG] Read notifications of bucket [%s]: %d queues, %d topics, %d lambdas", bucket,
+			len(queues), len(topics), len(lambdas))
+	}
+	return nil
+}
+
+func resourceS3BucketNotificationDelete(d *schema.ResourceData, meta interface{}) error {
+	debug := d.Get("debug").(bool)
+	bucket := d.Get("bucket").(string)
+	s3_client := meta.(*s3Client).clientFor("s3_bucket_notification.delete", bucket)
+	ctx, cancel := meta.(*s3Client).operationContext(d, schema.TimeoutDelete)
+	defer cancel()
+
+	if debug {
+		log.Printf("[DEBUG] Removing notifications of bucket [%s]", bucket)
+	}
+
+	err := meta.(*s3Client).retry(ctx, "s3_bucket_notification.delete", true, func() error {
+		return s3_client.RemoveAllBucketNotification(bucket)
+	})
+	if err != nil && !isGone(err) {
+		log.Printf("[FATAL] Unable to remove notifications of bucket [%s].  Error: %v", bucket, err)
+		return errors.New(fmt.Sprintf("Unable to remove notifications of bucket [%s].  Error: %v", bucket, err))
+	}
+
+	if debug {
+		log.Printf("[DEBUG] Removed notifications of bucket [%s]", bucket)
+	}
+	return nil
+}
+
+func resourceS3BucketNotificationImport(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	d.Set("bucket", d.Id())
+	d.Set("debug", false)
+	return []*schema.ResourceData{d}, nil
+}
+
+func expandNotificationConfig(m map[string]interface{}) minio.NotificationConfig {
+	config := minio.NotificationConfig{}
+	for _, event := range sortedStrings(m["events"].(*schema.Set)) {
+		config.AddEvents(minio.NotificationEventType(event))
+	}
+	if prefix := m["filter_prefix"].(string); len(prefix) > 0 {
+		config.AddFilterPrefix(prefix)
+	}
+	if suffix := m["filter_suffix"].(string); len(suffix) > 0 {
+		config.AddFilterSuffix(suffix)
+	}
+	return config
+}
+
+func flattenNotificationConfig(arn string, config minio.NotificationConfig) map[string]interface{} {
+	events := make([]string, 0, len(config.Events))
+	for _, event := range config.Events {
+		events = append(events, string(event))
+	}
+	sort.Strings(events)
+	m := map[string]interface{}{
+		"arn":           arn,
+		"events":        schema.NewSet(schema.HashString, stringsToInterfaces(events)),
+		"filter_prefix": "",
+		"filter_suffix": "",
+	}
+	if config.Filter != nil {
+		for _, rule := range config.Filter.S3Key.FilterRules {
+			switch rule.Name {
+			case "prefix":
+				m["filter_prefix"] = rule.Value
+			case "suffix":
+				m["filter_suffix"] = rule.Value
+			}
+		}
+	}
+	return m
+}
+
+func stringsToInterfaces(values []string) []interface{} {
+	list := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		list = append(list, v)
+	}
+	return list
+}
+
+func validateNotificationEvent(v interface{}, k string) (ws []string, errors []error) {
+	if !knownNotificationEvents[v.(string)] {
+		errors = append(errors, fmt.Errorf("%q contains an unknown notification event: %s", k, v.(string)))
+	}
+	return
+}
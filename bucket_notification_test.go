@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResourceS3BucketNotification(t *testing.T) {
+	s := newFakeS3(t)
+	s.putBucket("my-bucket", "")
+	meta := s.meta(t, nil)
+	r := resourceS3BucketNotification()
+
+	// Create
+	raw := map[string]interface{}{
+		"bucket": "my-bucket",
+		"queue": []interface{}{map[string]interface{}{
+			"arn":           "arn:minio:sqs::1:webhook",
+			"events":        []interface{}{"s3:ObjectCreated:*", "s3:ObjectRemoved:*"},
+			"filter_prefix": "uploads/",
+		}},
+	}
+	state := testApply(t, r, nil, raw, meta)
+	testCheckAttributes(t, state, map[string]string{"id": "my-bucket", "queue.#": "1"})
+	if !strings.Contains(string(s.subresource("my-bucket", "notification")), "arn:minio:sqs::1:webhook") {
+		t.Fatalf("expected the queue to be set, got: %s", s.subresource("my-bucket", "notification"))
+	}
+	testCheckNoPlan(t, r, state, raw, meta)
+
+	// Update
+	raw["topic"] = []interface{}{map[string]interface{}{
+		"arn":    "arn:minio:sns::1:topic",
+		"events": []interface{}{"s3:ObjectCreated:Put"},
+	}}
+	state = testApply(t, r, state, raw, meta)
+	testCheckAttributes(t, state, map[string]string{"queue.#": "1", "topic.#": "1"})
+	testCheckNoPlan(t, r, state, raw, meta)
+
+	// Import
+	imported := testImport(t, r, "my-bucket", meta)
+	testCheckAttributes(t, imported, map[string]string{"bucket": "my-bucket", "queue.#": "1", "topic.#": "1"})
+
+	// Destroy
+	testDestroy(t, r, state, meta)
+	if strings.Contains(string(s.subresource("my-bucket", "notification")), "arn:") {
+		t.Fatalf("expected the notifications to be removed, got: %s", s.subresource("my-bucket", "notification"))
+	}
+}
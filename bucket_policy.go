@@ -0,0 +1,336 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceS3BucketPolicy() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceS3BucketPolicyCreate,
+		Read:   resourceS3BucketPolicyRead,
+		Update: resourceS3BucketPolicyUpdate,
+		Delete: resourceS3BucketPolicyDelete,
+
+		Timeouts: defaultResourceTimeouts,
+
+		Importer: &schema.ResourceImporter{
+			State: resourceS3BucketPolicyImport,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"bucket": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"policy": {
+				Type:             schema.TypeString,
+				Required:         true,
+				ValidateFunc:     validatePolicy,
+				DiffSuppressFunc: suppressEquivalentPolicy,
+				StateFunc:        normalizePolicyState,
+			},
+			"debug": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+		},
+	}
+}
+
+func resourceS3BucketPolicyCreate(d *schema.ResourceData, meta interface{}) error {
+	if err := resourceS3BucketPolicyPut(d, meta, schema.TimeoutCreate); err != nil {
+		return err
+	}
+	d.SetId(d.Get("bucket").(string))
+	return resourceS3BucketPolicyRead(d, meta)
+}
+
+func resourceS3BucketPolicyUpdate(d *schema.ResourceData, meta interface{}) error {
+	if err := resourceS3BucketPolicyPut(d, meta, schema.TimeoutUpdate); err != nil {
+		return err
+	}
+	return resourceS3BucketPolicyRead(d, meta)
+}
+
+func resourceS3BucketPolicyPut(d *schema.ResourceData, meta interface{}, timeout string) error {
+	debug := d.Get("debug").(bool)
+	bucket := d.Get("bucket").(string)
+	s3_client := meta.(*s3Client).clientFor("s3_bucket_policy."+timeout, bucket)
+	ctx, cancel := meta.(*s3Client).operationContext(d, timeout)
+	defer cancel()
+
+	policy, err := normalizePolicy(d.Get("policy").(string))
+	if err != nil {
+		return errors.New(fmt.Sprintf("Invalid policy for bucket [%s].  Error: %v", bucket, err))
+	}
+
+	if debug {
+		log.Printf("[DEBUG] Setting policy of bucket [%s]: %s", bucket, policy)
+	}
+
+	err = meta.(*s3Client).retry(ctx, "s3_bucket_policy."+timeout, true, func() error {
+		return s3_client.SetBucketPolicy(bucket, policy)
+	})
+	if err != nil {
+		log.Printf("[FATAL] Unable to set policy of bucket [%s].  Error: %v", bucket, err)
+		return errors.New(fmt.Sprintf("Unable to set policy of bucket [%s].  Error: %v", bucket, err))
+	}
+
+	if debug {
+		log.Printf("[DEBUG] Set policy of bucket [%s]", bucket)
+	}
+	return nil
+}
+
+func resourceS3BucketPolicyRead(d *schema.ResourceData, meta interface{}) error {
+	debug := d.Get("debug").(bool)
+	bucket := d.Get("bucket").(string)
+	s3_client := meta.(*s3Client).clientFor("s3_bucket_policy.read", bucket)
+	ctx, cancel := meta.(*s3Client).operationContext(d, schema.TimeoutRead)
+	defer cancel()
+
+	if debug {
+		log.Printf("[DEBUG] Reading policy of bucket [%s]", bucket)
+	}
+
+	var policy string
+	err := meta.(*s3Client).retry(ctx, "s3_bucket_policy.read", true, func() (err error) {
+		policy, err = s3_client.GetBucketPolicy(bucket)
+		return err
+	})
+	if err != nil {
+		if isGone(err) {
+			log.Printf("[WARN] Bucket [%s] not found, removing policy from state", bucket)
+			d.SetId("")
+			return nil
+		}
+		log.Printf("[FATAL] Unable to read policy of bucket [%s].  Error: %v", bucket, err)
+		return errors.New(fmt.Sprintf("Unable to read policy of bucket [%s].  Error: %v", bucket, err))
+	}
+	if len(policy) < 1 {
+		log.Printf("[WARN] Bucket [%s] has no policy, removing from state", bucket)
+		d.SetId("")
+		return nil
+	}
+
+	normalized, err := normalizePolicy(policy)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Unable to parse policy of bucket [%s].  Error: %v", bucket, err))
+	}
+	d.Set("policy", normalized)
+
+	if debug {
+		log.Printf("[DEBUG] Read policy of bucket [%s]: %s", bucket, normalized)
+	}
+	return nil
+}
+
+func resourceS3BucketPolicyDelete(d *schema.ResourceData, meta interface{}) error {
+	debug := d.Get("debug").(bool)
+	bucket := d.Get("bucket").(string)
+	s3_client := meta.(*s3Client).clientFor("s3_bucket_policy.delete", bucket)
+	ctx, cancel := meta.(*s3Client).operationContext(d, schema.TimeoutDelete)
+	defer cancel()
+
+	if debug {
+		log.Printf("[DEBUG] Removing policy of bucket [%s]", bucket)
+	}
+
+	err := meta.(*s3Client).retry(ctx, "s3_bucket_policy.delete", true, func() error {
+		return s3_client.SetBucketPolicy(bucket, "")
+	})
+	if err != nil && !isGone(err) {
+		log.Printf("[FATAL] Unable to remove policy of bucket [%s].  Error: %v", bucket, err)
+		return errors.New(fmt.Sprintf("Unable to remove policy of bucket [%s].  Error: %v", bucket, err))
+	}
+
+	if debug {
+		log.Printf("[DEBUG] Removed policy of bucket [%s]", bucket)
+	}
+	return nil
+}
+
+func resourceS3BucketPolicyImport(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	d.Set("bucket", d.Id())
+	d.Set("debug", false)
+	return []*schema.ResourceData{d}, nil
+}
+
+// normalizeJSON returns the canonical form of a JSON document: no
+// insignificant whitespace and object keys in sorted order.
+func normalizeJSON(document string) (string, error) {
+	var value interface{}
+	if err := json.Unmarshal([]byte(document), &value); err != nil {
+		return "", err
+	}
+	normalized, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(normalized), nil
+}
+
+func normalizeJSONState(v interface{}) string {
+	normalized, err := normalizeJSON(v.(string))
+	if err != nil {
+		// Invalid JSON is reported by validateJSON.
+		return v.(string)
+	}
+	return normalized
+}
+
+func suppressEquivalentJSON(k, old, new string, d *schema.ResourceData) bool {
+	normalizedOld, err := normalizeJSON(old)
+	if err != nil {
+		return false
+	}
+	normalizedNew, err := normalizeJSON(new)
+	if err != nil {
+		return false
+	}
+	return normalizedOld == normalizedNew
+}
+
+// normalizePolicy returns the canonical form of a policy document, so that a
+// policy written by hand compares equal to the same policy returned by the S3
+// server: single values become arrays, arrays are sorted, a "*" principal is
+// written as {"AWS":["*"]} and statements are sorted.
+func normalizePolicy(document string) (string, error) {
+	var policy map[string]interface{}
+	if err := json.Unmarshal([]byte(document), &policy); err != nil {
+		return "", err
+	}
+
+	var statements []interface{}
+	switch v := policy["Statement"].(type) {
+	case []interface{}:
+		statements = v
+	case map[string]interface{}:
+		statements = []interface{}{v}
+	}
+	canonical := make([]string, 0, len(statements))
+	for _, raw := range statements {
+		statement, ok := raw.(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("policy statement must be an object, got: %v", raw)
+		}
+		for _, key := range []string{"Action", "NotAction", "Resource", "NotResource"} {
+			if v, ok := statement[key]; ok {
+				statement[key] = policyValues(v)
+			}
+		}
+		for _, key := range []string{"Principal", "NotPrincipal"} {
+			if v, ok := statement[key]; ok {
+				statement[key] = policyPrincipal(v)
+			}
+		}
+		if conditions, ok := statement["Condition"].(map[string]interface{}); ok {
+			for _, c := range conditions {
+				if values, ok := c.(map[string]interface{}); ok {
+					for key, v := range values {
+						values[key] = policyValues(v)
+					}
+				}
+			}
+		}
+		data, err := json.Marshal(statement)
+		if err != nil {
+			return "", err
+		}
+		canonical = append(canonical, string(data))
+	}
+	sort.Strings(canonical)
+
+	sorted := make([]interface{}, 0, len(canonical))
+	for _, c := range canonical {
+		sorted = append(sorted, json.RawMessage(c))
+	}
+	if _, ok := policy["Statement"]; ok {
+		policy["Statement"] = sorted
+	}
+	data, err := json.Marshal(policy)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// policyValues turns a single value or an array of values into a sorted
+// array without duplicates.
+func policyValues(v interface{}) interface{} {
+	var values []string
+	switch v := v.(type) {
+	case string:
+		values = []string{v}
+	case []interface{}:
+		for _, e := range v {
+			s, ok := e.(string)
+			if !ok {
+				return v
+			}
+			values = append(values, s)
+		}
+	default:
+		return v
+	}
+	sort.Strings(values)
+	unique := make([]interface{}, 0, len(values))
+	for i, s := range values {
+		if i == 0 || s != values[i-1] {
+			unique = append(unique, s)
+		}
+	}
+	return unique
+}
+
+func policyPrincipal(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		if v == "*" {
+			return map[string]interface{}{"AWS": []interface{}{"*"}}
+		}
+		return v
+	case map[string]interface{}:
+		for key, values := range v {
+			v[key] = policyValues(values)
+		}
+		return v
+	}
+	return v
+}
+
+func normalizePolicyState(v interface{}) string {
+	normalized, err := normalizePolicy(v.(string))
+	if err != nil {
+		// Invalid policies are reported by validatePolicy.
+		return v.(string)
+	}
+	return normalized
+}
+
+func suppressEquivalentPolicy(k, old, new string, d *schema.ResourceData) bool {
+	normalizedOld, err := normalizePolicy(old)
+	if err != nil {
+		return false
+	}
+	normalizedNew, err := normalizePolicy(new)
+	if err != nil {
+		return false
+	}
+	return normalizedOld == normalizedNew
+}
+
+func validatePolicy(v interface{}, k string) (ws []string, errors []error) {
+	if _, err := normalizePolicy(v.(string)); err != nil {
+		errors = append(errors, fmt.Errorf("%q contains an invalid policy: %v", k, err))
+	}
+	return
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/hashcode"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// S3 actions the provider knows about.  Actions in a policy document must be
+// one of these or a wildcard matching at least one of them.  Like in AWS and
+// Minio, action names are case insensitive.
+var knownS3Actions = map[string]bool{
+	"s3:AbortMultipartUpload":             true,
+	"s3:BypassGovernanceRetention":        true,
+	"s3:CreateBucket":                     true,
+	"s3:DeleteBucket":                     true,
+	"s3:DeleteBucketPolicy":               true,
+	"s3:DeleteBucketWebsite":              true,
+	"s3:DeleteObject":                     true,
+	"s3:DeleteObjectTagging":              true,
+	"s3:DeleteObjectVersion":              true,
+	"s3:DeleteObjectVersionTagging":       true,
+	"s3:GetAccelerateConfiguration":       true,
+	"s3:GetAnalyticsConfiguration":        true,
+	"s3:GetBucketAcl":                     true,
+	"s3:GetBucketCORS":                    true,
+	"s3:GetBucketLocation":                true,
+	"s3:GetBucketLogging":                 true,
+	"s3:GetBucketNotification":            true,
+	"s3:GetBucketObjectLockConfiguration": true,
+	"s3:GetBucketPolicy":                  true,
+	"s3:GetBucketRequestPayment":          true,
+	"s3:GetBucketTagging":                 true,
+	"s3:GetBucketVersioning":              true,
+	"s3:GetBucketWebsite":                 true,
+	"s3:GetEncryptionConfiguration":       true,
+	"s3:GetInventoryConfiguration":        true,
+	"s3:GetLifecycleConfiguration":        true,
+	"s3:GetMetricsConfiguration":          true,
+	"s3:GetObject":                        true,
+	"s3:GetObjectAcl":                     true,
+	"s3:GetObjectLegalHold":               true,
+	"s3:GetObjectRetention":               true,
+	"s3:GetObjectTagging":                 true,
+	"s3:GetObjectTorrent":                 true,
+	"s3:GetObjectVersion":                 true,
+	"s3:GetObjectVersionAcl":              true,
+	"s3:GetObjectVersionForReplication":   true,
+	"s3:GetObjectVersionTagging":          true,
+	"s3:GetObjectVersionTorrent":          true,
+	"s3:GetReplicationConfiguration":      true,
+	"s3:HeadBucket":                       true,
+	"s3:ListAllMyBuckets":                 true,
+	"s3:ListBucket":                       true,
+	"s3:ListBucketByTags":                 true,
+	"s3:ListBucketMultipartUploads":       true,
+	"s3:ListBucketVersions":               true,
+	"s3:ListenBucketNotification":         true,
+	"s3:ListMultipartUploadParts":         true,
+	"s3:ObjectOwnerOverrideToBucketOwner": true,
+	"s3:PutAccelerateConfiguration":       true,
+	"s3:PutAnalyticsConfiguration":        true,
+	"s3:PutBucketAcl":                     true,
+	"s3:PutBucketCORS":                    true,
+	"s3:PutBucketLogging":                 true,
+	"s3:PutBucketNotification":            true,
+	"s3:PutBucketObjectLockConfiguration": true,
+	"s3:PutBucketPolicy":                  true,
+	"s3:PutBucketRequestPayment":          true,
+	"s3:PutBucketTagging":                 true,
+	"s3:PutBucketVersioning":              true,
+	"s3:PutBucketWebsite":                 true,
+	"s3:PutEncryptionConfiguration":       true,
+	"s3:PutInventoryConfiguration":        true,
+	"s3:PutLifecycleConfiguration":        true,
+	"s3:PutMetricsConfiguration":          true,
+	"s3:PutObject":                        true,
+	"s3:PutObjectAcl":                     true,
+	"s3:PutObjectLegalHold":               true,
+	"s3:PutObjectRetention":               true,
+	"s3:PutObjectTagging":                 true,
+	"s3:PutObjectVersionAcl":              true,
+	"s3:PutObjectVersionTagging":          true,
+	"s3:PutReplicationConfiguration":      true,
+	"s3:ReplicateDelete":                  true,
+	"s3:ReplicateObject":                  true,
+	"s3:ReplicateTags":                    true,
+	"s3:RestoreObject":                    true,
+}
+
+// Actions granted by the canned policies, the same ones MinIO uses for its
+// none/readonly/writeonly/readwrite prefix policies.
+var (
+	commonBucketActions    = []string{"s3:GetBucketLocation"}
+	readOnlyBucketActions  = []string{"s3:ListBucket"}
+	writeOnlyBucketActions = []string{"s3:ListBucketMultipartUploads"}
+	readOnlyObjectActions  = []string{"s3:GetObject"}
+	writeOnlyObjectActions = []string{"s3:AbortMultipartUpload", "s3:DeleteObject", "s3:ListMultipartUploadParts", "s3:PutObject"}
+)
+
+type policyDocument struct {
+	Version   string            `json:"Version"`
+	Statement []policyStatement `json:"Statement"`
+}
+
+type policyStatement struct {
+	Sid       string                         `json:"Sid,omitempty"`
+	Effect    string                         `json:"Effect"`
+	Principal map[string][]string            `json:"Principal,omitempty"`
+	Action    []string                       `json:"Action"`
+	Resource  []string                       `json:"Resource"`
+	Condition map[string]map[string][]string `json:"Condition,omitempty"`
+}
+
+func dataSourceS3BucketPolicyDocument() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceS3BucketPolicyDocumentRead,
+
+		Schema: map[string]*schema.Schema{
+			"version": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Default:  "2012-10-17",
+			},
+			"canned": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "none",
+				ValidateFunc: validateCannedPolicy,
+			},
+			"bucket": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"prefix": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Default:  "",
+			},
+			"statement": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"sid": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"effect": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      "Allow",
+							ValidateFunc: validatePolicyEffect,
+						},
+						"actions": {
+							Type:     schema.TypeSet,
+							Required: true,
+							Elem: &schema.Schema{
+								Type:         schema.TypeString,
+								ValidateFunc: validateS3Action,
+							},
+						},
+						"resources": {
+							Type:     schema.TypeSet,
+							Required: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+						},
+						"principals": {
+							Type:     schema.TypeSet,
+							Optional: true,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"type": {
+										Type:     schema.TypeString,
+										Required: true,
+									},
+									"identifiers": {
+										Type:     schema.TypeSet,
+										Required: true,
+										Elem:     &schema.Schema{Type: schema.TypeString},
+									},
+								},
+							},
+						},
+						"conditions": {
+							Type:     schema.TypeSet,
+							Optional: true,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"test": {
+										Type:     schema.TypeString,
+										Required: true,
+									},
+									"variable": {
+										Type:     schema.TypeString,
+										Required: true,
+									},
+									"values": {
+										Type:     schema.TypeSet,
+										Required: true,
+										Elem:     &schema.Schema{Type: schema.TypeString},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			"json": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func dataSourceS3BucketPolicyDocumentRead(d *schema.ResourceData, meta interface{}) error {
+	document := policyDocument{Version: d.Get("version").(string)}
+
+	canned := d.Get("canned").(string)
+	if canned != "none" {
+		bucket := d.Get("bucket").(string)
+		if len(bucket) < 1 {
+			return fmt.Errorf("\"bucket\" is required for the %q canned policy", canned)
+		}
+		document.Statement = cannedPolicyStatements(canned, bucket, d.Get("prefix").(string))
+	}
+
+	for _, raw := range d.Get("statement").([]interface{}) {
+		s := raw.(map[string]interface{})
+		statement := policyStatement{
+			Sid:      s["sid"].(string),
+			Effect:   s["effect"].(string),
+			Action:   sortedStrings(s["actions"].(*schema.Set)),
+			Resource: sortedStrings(s["resources"].(*schema.Set)),
+		}
+		for _, p := range s["principals"].(*schema.Set).List() {
+			principal := p.(map[string]interface{})
+			if statement.Principal == nil {
+				statement.Principal = map[string][]string{}
+			}
+			principalType := principal["type"].(string)
+			statement.Principal[principalType] = mergeSorted(statement.Principal[principalType],
+				sortedStrings(principal["identifiers"].(*schema.Set)))
+		}
+		for _, c := range s["conditions"].(*schema.Set).List() {
+			condition := c.(map[string]interface{})
+			if statement.Condition == nil {
+				statement.Condition = map[string]map[string][]string{}
+			}
+			test := condition["test"].(string)
+			if statement.Condition[test] == nil {
+				statement.Condition[test] = map[string][]string{}
+			}
+			variable := condition["variable"].(string)
+			statement.Condition[test][variable] = mergeSorted(statement.Condition[test][variable],
+				sortedStrings(condition["values"].(*schema.Set)))
+		}
+		document.Statement = append(document.Statement, statement)
+	}
+
+	if len(document.Statement) < 1 {
+		return fmt.Errorf("policy document has no statements, set \"canned\" or add a \"statement\" block")
+	}
+
+	data, err := json.Marshal(document)
+	if err != nil {
+		return err
+	}
+	policy, err := normalizeJSON(string(data))
+	if err != nil {
+		return err
+	}
+	d.Set("json", policy)
+	d.SetId(strconv.Itoa(hashcode.String(policy)))
+	return nil
+}
+
+// cannedPolicyStatements returns the statements MinIO generates for a canned
+// prefix policy on bucket.
+func cannedPolicyStatements(canned, bucket, prefix string) []policyStatement {
+	read := canned == "readonly" || canned == "readwrite"
+	write := canned == "writeonly" || canned == "readwrite"
+	principal := map[string][]string{"AWS": {"*"}}
+	bucketResource := []string{"arn:aws:s3:::" + bucket}
+
+	bucketActions := append([]string{}, commonBucketActions...)
+	objectActions := []string{}
+	if read {
+		if len(prefix) < 1 {
+			bucketActions = append(bucketActions, readOnlyBucketActions...)
+		}
+		objectActions = append(objectActions, readOnlyObjectActions...)
+	}
+	if write {
+		bucketActions = append(bucketActions, writeOnlyBucketActions...)
+		objectActions = append(objectActions, writeOnlyObjectActions...)
+	}
+	sort.Strings(bucketActions)
+	sort.Strings(objectActions)
+
+	statements := []policyStatement{{
+		Effect:    "Allow",
+		Principal: principal,
+		Action:    bucketActions,
+		Resource:  bucketResource,
+	}}
+	if read && len(prefix) > 0 {
+		statements = append(statements, policyStatement{
+			Effect:    "Allow",
+			Principal: principal,
+			Action:    readOnlyBucketActions,
+			Resource:  bucketResource,
+			Condition: map[string]map[string][]string{
+				"StringEquals": {"s3:prefix": {prefix}},
+			},
+		})
+	}
+	statements = append(statements, policyStatement{
+		Effect:    "Allow",
+		Principal: principal,
+		Action:    objectActions,
+		Resource:  []string{"arn:aws:s3:::" + bucket + "/" + prefix + "*"},
+	})
+	return statements
+}
+
+func sortedStrings(set *schema.Set) []string {
+	values := make([]string, 0, set.Len())
+	for _, v := range set.List() {
+		values = append(values, v.(string))
+	}
+	sort.Strings(values)
+	return values
+}
+
+func mergeSorted(a, b []string) []string {
+	merged := append(a, b...)
+	sort.Strings(merged)
+	return merged
+}
+
+func validateCannedPolicy(v interface{}, k string) (ws []string, errors []error) {
+	switch v.(string) {
+	case "none", "readonly", "writeonly", "readwrite":
+	default:
+		errors = append(errors, fmt.Errorf("%q must be one of none, readonly, writeonly or readwrite, got: %s", k, v.(string)))
+	}
+	return
+}
+
+func validatePolicyEffect(v interface{}, k string) (ws []string, errors []error) {
+	switch v.(string) {
+	case "Allow", "Deny":
+	default:
+		errors = append(errors, fmt.Errorf("%q must be Allow or Deny, got: %s", k, v.(string)))
+	}
+	return
+}
+
+func validateS3Action(v interface{}, k string) (ws []string, errors []error) {
+	action := strings.ToLower(v.(string))
+	for known := range knownS3Actions {
+		if matched, _ := path.Match(action, strings.ToLower(known)); matched {
+			return
+		}
+	}
+	errors = append(errors, fmt.Errorf("%q contains an unknown S3 action: %s", k, action))
+	return
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// A hand written policy and the same policy as returned by Minio, which
+// expands single values to arrays, reorders them and writes the anonymous
+// principal as {"AWS":["*"]}.
+const (
+	testPolicyWritten = `{
+    "Version": "2012-10-17",
+    "Statement": [
+        {
+            "Action": "s3:GetObject",
+            "Effect": "Allow",
+            "Principal": "*",
+            "Resource": "arn:aws:s3:::my_bucket/*"
+        },
+        {
+            "Effect": "Allow",
+            "Principal": {"AWS": "*"},
+            "Action": ["s3:ListBucket", "s3:GetBucketLocation"],
+            "Resource": "arn:aws:s3:::my_bucket",
+            "Condition": {"StringEquals": {"s3:prefix": "public/"}}
+        }
+    ]
+}`
+	testPolicyReturned = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"AWS":["*"]},"Action":["s3:GetBucketLocation","s3:ListBucket"],"Resource":["arn:aws:s3:::my_bucket"],"Condition":{"StringEquals":{"s3:prefix":["public/"]}}},{"Effect":"Allow","Principal":{"AWS":["*"]},"Action":["s3:GetObject"],"Resource":["arn:aws:s3:::my_bucket/*"]}]}`
+)
+
+func TestNormalizePolicyRoundTrip(t *testing.T) {
+	written, err := normalizePolicy(testPolicyWritten)
+	if err != nil {
+		t.Fatal(err)
+	}
+	returned, err := normalizePolicy(testPolicyReturned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written != returned {
+		t.Fatalf("policies differ:\n%s\n%s", written, returned)
+	}
+	if !suppressEquivalentPolicy("policy", testPolicyReturned, testPolicyWritten, nil) {
+		t.Fatal("equivalent policies are not suppressed")
+	}
+
+	again, err := normalizePolicy(written)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != written {
+		t.Fatalf("normalizePolicy is not idempotent:\n%s\n%s", written, again)
+	}
+}
+
+func TestNormalizePolicyDifferent(t *testing.T) {
+	other := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:PutObject","Resource":"arn:aws:s3:::my_bucket/*"}]}`
+	if suppressEquivalentPolicy("policy", testPolicyReturned, other, nil) {
+		t.Fatal("different policies are suppressed")
+	}
+}
+
+func TestValidatePolicy(t *testing.T) {
+	if _, errs := validatePolicy("{not json", "policy"); len(errs) != 1 {
+		t.Fatalf("expected an error for invalid JSON, got: %v", errs)
+	}
+	if _, errs := validatePolicy(`{"Statement":["s3:GetObject"]}`, "policy"); len(errs) != 1 {
+		t.Fatalf("expected an error for an invalid statement, got: %v", errs)
+	}
+	if _, errs := validatePolicy(testPolicyWritten, "policy"); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+}
+
+func TestValidateS3Action(t *testing.T) {
+	cases := []struct {
+		action string
+		valid  bool
+	}{
+		{"s3:GetObject", true},
+		{"S3:getobject", true},
+		{"s3:PutObjectAcl", true},
+		{"s3:GetObjectTagging", true},
+		{"s3:PutBucketTagging", true},
+		{"s3:GetEncryptionConfiguration", true},
+		{"s3:ReplicateObject", true},
+		{"s3:*", true},
+		{"s3:Get*Acl", true},
+		{"s3:get*tagging", true},
+		{"s3:GetObjects", false},
+		{"s3:Frobnicate*", false},
+		{"GetObject", false},
+	}
+	for _, c := range cases {
+		_, errs := validateS3Action(c.action, "actions")
+		if valid := len(errs) == 0; valid != c.valid {
+			t.Errorf("validateS3Action(%q): expected valid = %v, got: %v", c.action, c.valid, errs)
+		}
+	}
+}
+
+func TestResourceS3BucketPolicy(t *testing.T) {
+	s := newFakeS3(t)
+	s.putBucket("my_bucket", "")
+	meta := s.meta(t, nil)
+	r := resourceS3BucketPolicy()
+
+	// Create
+	raw := map[string]interface{}{"bucket": "my_bucket", "policy": testPolicyWritten}
+	state := testApply(t, r, nil, raw, meta)
+	testCheckAttributes(t, state, map[string]string{"id": "my_bucket"})
+	if len(s.subresource("my_bucket", "policy")) < 1 {
+		t.Fatal("expected the policy to be set")
+	}
+
+	// The server returns the policy the way Minio does.
+	s.setSubresource("my_bucket", "policy", []byte(testPolicyReturned))
+	testCheckNoPlan(t, r, state, raw, meta)
+
+	// Update
+	raw["policy"] = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::my_bucket/public/*"}]}`
+	state = testApply(t, r, state, raw, meta)
+	if !strings.Contains(string(s.subresource("my_bucket", "policy")), "public/*") {
+		t.Fatalf("expected the policy to be updated, got: %s", s.subresource("my_bucket", "policy"))
+	}
+	testCheckNoPlan(t, r, state, raw, meta)
+
+	// Import
+	imported := testImport(t, r, "my_bucket", meta)
+	testCheckAttributes(t, imported, map[string]string{"bucket": "my_bucket", "policy": state.Attributes["policy"]})
+
+	// Destroy
+	testDestroy(t, r, state, meta)
+	if s.subresource("my_bucket", "policy") != nil {
+		t.Fatal("expected the policy to be removed")
+	}
+	if state := testRefresh(t, r, state, meta); state != nil {
+		t.Fatalf("expected the removed policy to leave the state, got: %v", state)
+	}
+}
+
+func TestDataSourceS3BucketPolicyDocument(t *testing.T) {
+	r := dataSourceS3BucketPolicyDocument()
+	raw := map[string]interface{}{
+		"canned": "readonly",
+		"bucket": "my_bucket",
+		"statement": []interface{}{map[string]interface{}{
+			"effect":    "Allow",
+			"actions":   []interface{}{"s3:PutObject"},
+			"resources": []interface{}{"arn:aws:s3:::my_bucket/uploads/*"},
+			"principals": []interface{}{map[string]interface{}{
+				"type":        "AWS",
+				"identifiers": []interface{}{"arn:aws:iam::123456789012:root"},
+			}},
+		}},
+	}
+	diff, err := r.Diff(nil, testResourceConfig(t, raw), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := r.ReadDataApply(diff, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	document := state.Attributes["json"]
+	for _, want := range []string{`"s3:GetObject"`, `"s3:PutObject"`, `"arn:aws:s3:::my_bucket/uploads/*"`, `"arn:aws:iam::123456789012:root"`} {
+		if !strings.Contains(document, want) {
+			t.Errorf("expected %s in the policy document, got: %s", want, document)
+		}
+	}
+	if _, err := normalizePolicy(document); err != nil {
+		t.Fatalf("expected a valid policy document, got: %v", err)
+	}
+}
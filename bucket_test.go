@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestEquivalentRegions(t *testing.T) {
+	cases := []struct {
+		a, b  string
+		equal bool
+	}{
+		{"EU", "eu-west-1", true},
+		{"eu-west-1", "EU", true},
+		{"us-east-1", "us-east-1", true},
+		{"us-east-1", "eu-west-1", false},
+		{"EU", "eu-central-1", false},
+	}
+	for _, c := range cases {
+		if equal := equivalentRegions(c.a, c.b); equal != c.equal {
+			t.Errorf("equivalentRegions(%q, %q) = %v, want %v", c.a, c.b, equal, c.equal)
+		}
+	}
+}
+
+func TestResourceS3Bucket(t *testing.T) {
+	s := newFakeS3(t)
+	meta := s.meta(t, nil)
+	r := resourceS3Bucket()
+
+	// Create
+	raw := map[string]interface{}{
+		"bucket":     "my-bucket",
+		"region":     "eu-west-1",
+		"versioning": []interface{}{map[string]interface{}{"enabled": true}},
+	}
+	state := testApply(t, r, nil, raw, meta)
+	testCheckAttributes(t, state, map[string]string{
+		"id":                   "my-bucket",
+		"region":               "eu-west-1",
+		"versioning.#":         "1",
+		"versioning.0.enabled": "true",
+	})
+	if !strings.Contains(string(s.subresource("my-bucket", "versioning")), "<Status>Enabled</Status>") {
+		t.Fatalf("expected versioning to be enabled, got: %s", s.subresource("my-bucket", "versioning"))
+	}
+	testCheckNoPlan(t, r, state, raw, meta)
+
+	// Update
+	raw["versioning"] = []interface{}{map[string]interface{}{"enabled": false}}
+	state = testApply(t, r, state, raw, meta)
+	if !strings.Contains(string(s.subresource("my-bucket", "versioning")), "<Status>Suspended</Status>") {
+		t.Fatalf("expected versioning to be suspended, got: %s", s.subresource("my-bucket", "versioning"))
+	}
+	testCheckNoPlan(t, r, state, raw, meta)
+
+	// Rename
+	raw["bucket"] = "my-renamed-bucket"
+	state = testApply(t, r, state, raw, meta)
+	testCheckAttributes(t, state, map[string]string{"id": "my-renamed-bucket"})
+	if s.hasBucket("my-bucket") || !s.hasBucket("my-renamed-bucket") {
+		t.Fatal("expected the bucket to be re-created under its new name")
+	}
+
+	// Import
+	imported := testImport(t, r, "my-renamed-bucket", meta)
+	testCheckAttributes(t, imported, map[string]string{
+		"bucket":        "my-renamed-bucket",
+		"region":        "eu-west-1",
+		"force_destroy": "false",
+	})
+
+	// Destroy
+	testDestroy(t, r, state, meta)
+	if s.hasBucket("my-renamed-bucket") {
+		t.Fatal("expected the bucket to be removed")
+	}
+	if state := testRefresh(t, r, state, meta); state != nil {
+		t.Fatalf("expected the removed bucket to leave the state, got: %v", state)
+	}
+}
+
+func TestResourceS3BucketRegionDrift(t *testing.T) {
+	s := newFakeS3(t)
+	meta := s.meta(t, nil)
+	r := resourceS3Bucket()
+
+	// The legacy EU location is the same region as eu-west-1.
+	raw := map[string]interface{}{"bucket": "legacy", "region": "EU"}
+	state := testApply(t, r, nil, raw, meta)
+	testCheckAttributes(t, state, map[string]string{"region": "EU"})
+	testCheckNoPlan(t, r, state, raw, meta)
+	raw["region"] = "eu-west-1"
+	testCheckNoPlan(t, r, state, raw, meta)
+
+	// A bucket found in another region than configured by a later run is
+	// planned to be replaced.
+	raw = map[string]interface{}{"bucket": "moved", "region": "eu-west-1"}
+	state = testApply(t, r, nil, raw, meta)
+	s.putBucket("moved", "us-west-2")
+	diff := testPlan(t, r, state, raw, s.meta(t, nil))
+	if diff == nil || diff.Attributes["region"] == nil || diff.Attributes["region"].Old != "us-west-2" || !diff.RequiresNew() {
+		t.Fatalf("expected the region drift to plan a replacement, got: %#v", diff)
+	}
+}
+
+func TestResourceS3BucketUnconfiguredVersioning(t *testing.T) {
+	s := newFakeS3(t)
+	meta := s.meta(t, nil)
+	r := resourceS3Bucket()
+	raw := map[string]interface{}{"bucket": "plain"}
+	state := testApply(t, r, nil, raw, meta)
+
+	// Servers without versioning support must not break buckets that do not
+	// ask for it.
+	s.inject(fakeFault{subresource: "versioning", status: http.StatusNotImplemented, code: "NotImplemented"})
+	testCheckNoPlan(t, r, state, raw, meta)
+	if n := s.count("GET /plain?versioning"); n != 0 {
+		t.Fatalf("expected versioning not to be read, got %d requests", n)
+	}
+}
+
+func TestResourceS3BucketMFADelete(t *testing.T) {
+	s := newFakeS3(t)
+	meta := s.meta(t, nil)
+	r := resourceS3Bucket()
+	raw := map[string]interface{}{
+		"bucket":     "mfa",
+		"versioning": []interface{}{map[string]interface{}{"enabled": true}},
+	}
+	state := testApply(t, r, nil, raw, meta)
+
+	// Enabling MFA delete takes the MFA device.
+	raw["versioning"] = []interface{}{map[string]interface{}{"enabled": true, "mfa_delete": true}}
+	diff, err := r.Diff(state, testResourceConfig(t, raw), meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Apply(state, diff, meta); err == nil || !strings.Contains(err.Error(), "mfa") {
+		t.Fatalf("expected MFA delete without mfa to fail, got: %v", err)
+	}
+
+	raw["versioning"] = []interface{}{map[string]interface{}{
+		"enabled": true, "mfa_delete": true, "mfa": "arn:aws:iam::123456789012:mfa/root 123456",
+	}}
+	state = testApply(t, r, state, raw, meta)
+	if !strings.Contains(string(s.subresource("mfa", "versioning")), "<MfaDelete>Enabled</MfaDelete>") {
+		t.Fatalf("expected MFA delete to be enabled, got: %s", s.subresource("mfa", "versioning"))
+	}
+	testCheckAttributes(t, state, map[string]string{"versioning.0.mfa_delete": "true"})
+	testCheckNoPlan(t, r, state, raw, meta)
+
+	// So does changing the versioning of the bucket from then on.
+	raw["versioning"] = []interface{}{map[string]interface{}{
+		"enabled": false, "mfa_delete": true, "mfa": "arn:aws:iam::123456789012:mfa/root 654321",
+	}}
+	state = testApply(t, r, state, raw, meta)
+	if !strings.Contains(string(s.subresource("mfa", "versioning")), "<Status>Suspended</Status>") {
+		t.Fatalf("expected versioning to be suspended, got: %s", s.subresource("mfa", "versioning"))
+	}
+	testCheckNoPlan(t, r, state, raw, meta)
+}
+
+func TestResourceS3BucketForceDestroy(t *testing.T) {
+	s := newFakeS3(t)
+	meta := s.meta(t, nil)
+	r := resourceS3Bucket()
+	state := testApply(t, r, nil, map[string]interface{}{"bucket": "full", "force_destroy": true}, meta)
+	for i := 0; i < 2500; i++ {
+		s.putObject("full", fmt.Sprintf("objects/%04d", i), "text/plain", []byte("content"))
+	}
+
+	testDestroy(t, r, state, meta)
+	if s.hasBucket("full") {
+		t.Fatal("expected the bucket to be emptied and removed")
+	}
+}
+
+func TestResourceS3BucketForceDestroyFailure(t *testing.T) {
+	s := newFakeS3(t)
+	meta := s.meta(t, nil)
+	r := resourceS3Bucket()
+	state := testApply(t, r, nil, map[string]interface{}{"bucket": "denied", "force_destroy": true}, meta)
+	s.putObject("denied", "object.txt", "text/plain", []byte("content"))
+
+	s.inject(fakeFault{method: "POST", bucket: "denied", subresource: "delete", status: http.StatusForbidden, code: "AccessDenied"})
+	if _, err := r.Apply(state, &terraform.InstanceDiff{Destroy: true}, meta); err == nil {
+		t.Fatal("expected emptying the bucket to fail")
+	}
+	if !s.hasBucket("denied") {
+		t.Fatal("expected the bucket to be kept")
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/minio/minio-go"
+)
+
+const s3XMLNamespace = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+type versioningConfiguration struct {
+	XMLName   xml.Name `xml:"VersioningConfiguration"`
+	Xmlns     string   `xml:"xmlns,attr,omitempty"`
+	Status    string   `xml:"Status,omitempty"`
+	MfaDelete string   `xml:"MfaDelete,omitempty"`
+}
+
+// bucketVersioning returns the versioning configuration of bucket.  Status is
+// empty when versioning has never been enabled.
+func (c *s3Client) bucketVersioning(ctx context.Context, bucket string) (versioningConfiguration, error) {
+	var config versioningConfiguration
+	var data []byte
+	err := c.retry(ctx, "s3_bucket.versioning", true, func() (err error) {
+		data, err = c.bucketRequest(ctx, "s3_bucket.versioning", "GET", bucket, url.Values{"versioning": {""}}, nil)
+		return err
+	})
+	if err != nil {
+		return config, err
+	}
+	if err := xml.Unmarshal(data, &config); err != nil {
+		return config, errors.New(fmt.Sprintf("Unable to parse versioning of bucket [%s].  Error: %v", bucket, err))
+	}
+	return config, nil
+}
+
+// putBucketVersioning replaces the versioning configuration of bucket.  mfa is
+// the serial number of the MFA device and the code it shows, separated by a
+// space.  Amazon S3 requires it to change MfaDelete and to change the
+// versioning of a bucket with MfaDelete enabled.
+func (c *s3Client) putBucketVersioning(ctx context.Context, bucket string, config versioningConfiguration, mfa string) error {
+	config.Xmlns = s3XMLNamespace
+	body, err := xml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	var header http.Header
+	if len(mfa) > 0 {
+		header = http.Header{"X-Amz-Mfa": {mfa}}
+	}
+	return c.retry(ctx, "s3_bucket.versioning", true, func() error {
+		_, err := c.bucketRequestWithHeader(ctx, "s3_bucket.versioning", "PUT", bucket, url.Values{"versioning": {""}}, header, body)
+		return err
+	})
+}
+
+// isNotImplemented reports whether err means the S3 server does not support
+// the requested bucket subresource, e.g. versioning on older Minio releases.
+func isNotImplemented(err error) bool {
+	resp := minio.ToErrorResponse(err)
+	return unsupportedS3Codes[resp.Code] || resp.StatusCode == http.StatusNotImplemented
+}
+
+// isAccessDenied reports whether the credentials are not allowed to perform
+// the request.
+func isAccessDenied(err error) bool {
+	resp := minio.ToErrorResponse(err)
+	return resp.Code == "AccessDenied" || resp.StatusCode == http.StatusForbidden
+}
+
+// expandVersioning returns the versioning configuration for the versioning
+// block of an s3_bucket.  Versioning can only be suspended once it has been
+// enabled, so a disabled block maps to the Suspended status.
+func expandVersioning(m map[string]interface{}, mfaDeleteChanged bool) versioningConfiguration {
+	config := versioningConfiguration{Status: "Suspended"}
+	if m["enabled"].(bool) {
+		config.Status = "Enabled"
+	}
+	if m["mfa_delete"].(bool) {
+		config.MfaDelete = "Enabled"
+	} else if mfaDeleteChanged {
+		config.MfaDelete = "Disabled"
+	}
+	return config
+}
+
+// flattenVersioning returns the versioning block for config.  The S3 server
+// never returns the MFA device, so mfa is kept as configured.
+func flattenVersioning(config versioningConfiguration, mfa string) []interface{} {
+	return []interface{}{map[string]interface{}{
+		"enabled":    config.Status == "Enabled",
+		"mfa_delete": config.MfaDelete == "Enabled",
+		"mfa":        mfa,
+	}}
+}
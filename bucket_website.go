@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/minio/minio-go"
+	"github.com/minio/minio-go/pkg/s3utils"
+)
+
+// Amazon S3 regions whose website endpoints use s3-website-<region> instead of
+// s3-website.<region>.
+var dashedWebsiteRegions = map[string]bool{
+	"us-east-1":      true,
+	"us-west-1":      true,
+	"us-west-2":      true,
+	"ap-southeast-1": true,
+	"ap-southeast-2": true,
+	"ap-northeast-1": true,
+	"eu-west-1":      true,
+	"sa-east-1":      true,
+	"us-gov-west-1":  true,
+}
+
+type websiteConfiguration struct {
+	XMLName               xml.Name               `xml:"WebsiteConfiguration"`
+	Xmlns                 string                 `xml:"xmlns,attr,omitempty"`
+	RedirectAllRequestsTo *websiteRedirectAll    `xml:"RedirectAllRequestsTo,omitempty"`
+	IndexDocument         *websiteIndexDocument  `xml:"IndexDocument,omitempty"`
+	ErrorDocument         *websiteErrorDocument  `xml:"ErrorDocument,omitempty"`
+	RoutingRules          *websiteRoutingRuleSet `xml:"RoutingRules,omitempty"`
+}
+
+type websiteRedirectAll struct {
+	HostName string `xml:"HostName"`
+	Protocol string `xml:"Protocol,omitempty"`
+}
+
+type websiteIndexDocument struct {
+	Suffix string `xml:"Suffix"`
+}
+
+type websiteErrorDocument struct {
+	Key string `xml:"Key"`
+}
+
+type websiteRoutingRuleSet struct {
+	Rules []websiteRoutingRule `xml:"RoutingRule"`
+}
+
+// websiteRoutingRule is both the XML routing rule and its JSON form in the
+// routing_rules argument, which uses the same names as the S3 API.
+type websiteRoutingRule struct {
+	Condition *websiteRoutingCondition `xml:"Condition,omitempty" json:"Condition,omitempty"`
+	Redirect  websiteRoutingRedirect   `xml:"Redirect" json:"Redirect"`
+}
+
+type websiteRoutingCondition struct {
+	KeyPrefixEquals             string `xml:"KeyPrefixEquals,omitempty" json:"KeyPrefixEquals,omitempty"`
+	HttpErrorCodeReturnedEquals string `xml:"HttpErrorCodeReturnedEquals,omitempty" json:"HttpErrorCodeReturnedEquals,omitempty"`
+}
+
+type websiteRoutingRedirect struct {
+	Protocol             string `xml:"Protocol,omitempty" json:"Protocol,omitempty"`
+	HostName             string `xml:"HostName,omitempty" json:"HostName,omitempty"`
+	ReplaceKeyPrefixWith string `xml:"ReplaceKeyPrefixWith,omitempty" json:"ReplaceKeyPrefixWith,omitempty"`
+	ReplaceKeyWith       string `xml:"ReplaceKeyWith,omitempty" json:"ReplaceKeyWith,omitempty"`
+	HttpRedirectCode     string `xml:"HttpRedirectCode,omitempty" json:"HttpRedirectCode,omitempty"`
+}
+
+func resourceS3BucketWebsite() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceS3BucketWebsiteCreate,
+		Read:   resourceS3BucketWebsiteRead,
+		Update: resourceS3BucketWebsiteUpdate,
+		Delete: resourceS3BucketWebsiteDelete,
+
+		Timeouts: defaultResourceTimeouts,
+
+		Importer: &schema.ResourceImporter{
+			State: resourceS3BucketWebsiteImport,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"bucket": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"index_document": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"redirect_all_requests_to"},
+			},
+			"error_document": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"redirect_all_requests_to"},
+			},
+			"redirect_all_requests_to": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"index_document", "error_document", "routing_rules"},
+			},
+			"routing_rules": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				ValidateFunc:     validateRoutingRules,
+				DiffSuppressFunc: suppressEquivalentJSON,
+				StateFunc:        normalizeJSONState,
+			},
+			"website_endpoint": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"debug": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+		},
+	}
+}
+
+func resourceS3BucketWebsiteCreate(d *schema.ResourceData, meta interface{}) error {
+	if err := resourceS3BucketWebsitePut(d, meta, schema.TimeoutCreate); err != nil {
+		return err
+	}
+	d.SetId(d.Get("bucket").(string))
+	return resourceS3BucketWebsiteRead(d, meta)
+}
+
+func resourceS3BucketWebsiteUpdate(d *schema.ResourceData, meta interface{}) error {
+	if err := resourceS3BucketWebsitePut(d, meta, schema.TimeoutUpdate); err != nil {
+		return err
+	}
+	return resourceS3BucketWebsiteRead(d, meta)
+}
+
+func resourceS3BucketWebsitePut(d *schema.ResourceData, meta interface{}, timeout string) error {
+	debug := d.Get("debug").(bool)
+	bucket := d.Get("bucket").(string)
+	ctx, cancel := meta.(*s3Client).operationContext(d, timeout)
+	defer cancel()
+
+	config := websiteConfiguration{Xmlns: s3XMLNamespace}
+	if redirect := d.Get("redirect_all_requests_to").(string); len(redirect) > 0 {
+		config.RedirectAllRequestsTo = &websiteRedirectAll{HostName: redirect}
+		if u, err := url.Parse(redirect); err == nil && len(u.Scheme) > 0 {
+			config.RedirectAllRequestsTo = &websiteRedirectAll{HostName: u.Host, Protocol: u.Scheme}
+		}
+	} else if index := d.Get("index_document").(string); len(index) > 0 {
+		config.IndexDocument = &websiteIndexDocument{Suffix: index}
+	} else {
+		return errors.New(fmt.Sprintf("Unable to set website of bucket [%s].  Either index_document or redirect_all_requests_to must be set", bucket))
+	}
+	if key := d.Get("error_document").(string); len(key) > 0 {
+		config.ErrorDocument = &websiteErrorDocument{Key: key}
+	}
+	if rules := d.Get("routing_rules").(string); len(rules) > 0 {
+		config.RoutingRules = &websiteRoutingRuleSet{}
+		if err := json.Unmarshal([]byte(rules), &config.RoutingRules.Rules); err != nil {
+			return errors.New(fmt.Sprintf("Invalid routing rules for bucket [%s].  Error: %v", bucket, err))
+		}
+	}
+	body, err := xml.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	if debug {
+		log.Printf("[DEBUG] Setting website of bucket [%s]: %s", bucket, body)
+	}
+
+	err = meta.(*s3Client).retry(ctx, "s3_bucket_website."+timeout, true, func() error {
+		_, err := meta.(*s3Client).bucketRequest(ctx, "s3_bucket_website."+timeout, "PUT", bucket, url.Values{"website": {""}}, body)
+		return err
+	})
+	if err != nil {
+		if isNotImplemented(err) {
+			return errors.New(fmt.Sprintf("Unable to set website of bucket [%s].  The S3 server does not support static website hosting", bucket))
+		}
+		log.Printf("[FATAL] Unable to set website of bucket [%s].  Error: %v", bucket, err)
+		return errors.New(fmt.Sprintf("Unable to set website of bucket [%s].  Error: %v", bucket, err))
+	}
+
+	if debug {
+		log.Printf("[DEBUG] Set website of bucket [%s]", bucket)
+	}
+	return nil
+}
+
+func resourceS3BucketWebsiteRead(d *schema.ResourceData, meta interface{}) error {
+	debug := d.Get("debug").(bool)
+	bucket := d.Get("bucket").(string)
+	ctx, cancel := meta.(*s3Client).operationContext(d, schema.TimeoutRead)
+	defer cancel()
+
+	if debug {
+		log.Printf("[DEBUG] Reading website of bucket [%s]", bucket)
+	}
+
+	var data []byte
+	err := meta.(*s3Client).retry(ctx, "s3_bucket_website.read", true, func() (err error) {
+		data, err = meta.(*s3Client).bucketRequest(ctx, "s3_bucket_website.read", "GET", bucket, url.Values{"website": {""}}, nil)
+		return err
+	})
+	if err != nil {
+		if isGone(err) || minio.ToErrorResponse(err).Code == "NoSuchWebsiteConfiguration" {
+			log.Printf("[WARN] Website of bucket [%s] not found, removing from state", bucket)
+			d.SetId("")
+			return nil
+		}
+		log.Printf("[FATAL] Unable to read website of bucket [%s].  Error: %v", bucket, err)
+		return errors.New(fmt.Sprintf("Unable to read website of bucket [%s].  Error: %v", bucket, err))
+	}
+
+	var config websiteConfiguration
+	if err := xml.Unmarshal(data, &config); err != nil {
+		return errors.New(fmt.Sprintf("Unable to parse website of bucket [%s].  Error: %v", bucket, err))
+	}
+
+	redirect := ""
+	if r := config.RedirectAllRequestsTo; r != nil {
+		redirect = r.HostName
+		if len(r.Protocol) > 0 {
+			redirect = r.Protocol + "://" + r.HostName
+		}
+	}
+	d.Set("redirect_all_requests_to", redirect)
+	index := ""
+	if config.IndexDocument != nil {
+		index = config.IndexDocument.Suffix
+	}
+	d.Set("index_document", index)
+	errorDocument := ""
+	if config.ErrorDocument != nil {
+		errorDocument = config.ErrorDocument.Key
+	}
+	d.Set("error_document", errorDocument)
+	rules := ""
+	if config.RoutingRules != nil && len(config.RoutingRules.Rules) > 0 {
+		data, err := json.Marshal(config.RoutingRules.Rules)
+		if err != nil {
+			return err
+		}
+		rules = string(data)
+	}
+	d.Set("routing_rules", rules)
+
+	endpoint, err := meta.(*s3Client).websiteEndpoint(ctx, bucket)
+	if err != nil {
+		log.Printf("[FATAL] Unable to read location of bucket [%s].  Error: %v", bucket, err)
+		return errors.New(fmt.Sprintf("Unable to read location of bucket [%s].  Error: %v", bucket, err))
+	}
+	d.Set("website_endpoint", endpoint)
+
+	if debug {
+		log.Printf("[DEBUG] Read website of bucket [%s] served at [%s]", bucket, endpoint)
+	}
+	return nil
+}
+
+func resourceS3BucketWebsiteDelete(d *schema.ResourceData, meta interface{}) error {
+	debug := d.Get("debug").(bool)
+	bucket := d.Get("bucket").(string)
+	ctx, cancel := meta.(*s3Client).operationContext(d, schema.TimeoutDelete)
+	defer cancel()
+
+	if debug {
+		log.Printf("[DEBUG] Removing website of bucket [%s]", bucket)
+	}
+
+	err := meta.(*s3Client).retry(ctx, "s3_bucket_website.delete", true, func() error {
+		_, err := meta.(*s3Client).bucketRequest(ctx, "s3_bucket_website.delete", "DELETE", bucket, url.Values{"website": {""}}, nil)
+		return err
+	})
+	if err != nil && !isGone(err) && minio.ToErrorResponse(err).Code != "NoSuchWebsiteConfiguration" {
+		log.Printf("[FATAL] Unable to remove website of bucket [%s].  Error: %v", bucket, err)
+		return errors.New(fmt.Sprintf("Unable to remove website of bucket [%s].  Error: %v", bucket, err))
+	}
+
+	if debug {
+		log.Printf("[DEBUG] Removed website of bucket [%s]", bucket)
+	}
+	return nil
+}
+
+func resourceS3BucketWebsiteImport(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	d.Set("bucket", d.Id())
+	d.Set("debug", false)
+	return []*schema.ResourceData{d}, nil
+}
+
+// websiteEndpoint returns the host serving the website of bucket.  Amazon S3
+// has dedicated website endpoints per region, other servers are assumed to
+// serve websites from the virtual host of the bucket.
+func (c *s3Client) websiteEndpoint(ctx context.Context, bucket string) (string, error) {
+	if !s3utils.IsAmazonEndpoint(url.URL{Host: c.endpoint}) {
+		return bucket + "." + c.endpoint, nil
+	}
+	var location string
+	err := c.retry(ctx, "s3_bucket_website.read", true, func() (err error) {
+		location, err = c.s3Client.GetBucketLocation(bucket)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	if location == "" {
+		location = "us-east-1"
+	}
+	if dashedWebsiteRegions[location] {
+		return fmt.Sprintf("%s.s3-website-%s.amazonaws.com", bucket, location), nil
+	}
+	return fmt.Sprintf("%s.s3-website.%s.amazonaws.com", bucket, location), nil
+}
+
+func validateRoutingRules(v interface{}, k string) (ws []string, errors []error) {
+	var rules []websiteRoutingRule
+	if err := json.Unmarshal([]byte(v.(string)), &rules); err != nil {
+		errors = append(errors, fmt.Errorf("%q must be a JSON array of routing rules: %v", k, err))
+		return
+	}
+	for i, rule := range rules {
+		if rule.Redirect == (websiteRoutingRedirect{}) {
+			errors = append(errors, fmt.Errorf("%q: routing rule %d has no Redirect", k, i))
+		}
+		if p := rule.Redirect.Protocol; p != "" && p != "http" && p != "https" {
+			errors = append(errors, fmt.Errorf("%q: routing rule %d Protocol must be http or https, got: %s", k, i, p))
+		}
+	}
+	return
+}
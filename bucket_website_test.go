@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResourceS3BucketWebsite(t *testing.T) {
+	s := newFakeS3(t)
+	s.putBucket("my-bucket", "")
+	meta := s.meta(t, nil)
+	r := resourceS3BucketWebsite()
+
+	// Create
+	raw := map[string]interface{}{
+		"bucket":         "my-bucket",
+		"index_document": "index.html",
+		"error_document": "error.html",
+	}
+	state := testApply(t, r, nil, raw, meta)
+	testCheckAttributes(t, state, map[string]string{"id": "my-bucket", "index_document": "index.html"})
+	if !strings.Contains(string(s.subresource("my-bucket", "website")), "<Suffix>index.html</Suffix>") {
+		t.Fatalf("expected the website to be set, got: %s", s.subresource("my-bucket", "website"))
+	}
+	testCheckNoPlan(t, r, state, raw, meta)
+
+	// Update
+	raw["routing_rules"] = `[{"Condition": {"KeyPrefixEquals": "docs/"}, "Redirect": {"ReplaceKeyPrefixWith": "documents/"}}]`
+	state = testApply(t, r, state, raw, meta)
+	if !strings.Contains(string(s.subresource("my-bucket", "website")), "<ReplaceKeyPrefixWith>documents/</ReplaceKeyPrefixWith>") {
+		t.Fatalf("expected the routing rules to be set, got: %s", s.subresource("my-bucket", "website"))
+	}
+	testCheckNoPlan(t, r, state, raw, meta)
+
+	// Import
+	imported := testImport(t, r, "my-bucket", meta)
+	testCheckAttributes(t, imported, map[string]string{
+		"bucket":         "my-bucket",
+		"index_document": "index.html",
+		"error_document": "error.html",
+		"routing_rules":  state.Attributes["routing_rules"],
+	})
+
+	// Destroy
+	testDestroy(t, r, state, meta)
+	if s.subresource("my-bucket", "website") != nil {
+		t.Fatal("expected the website configuration to be removed")
+	}
+	if state := testRefresh(t, r, state, meta); state != nil {
+		t.Fatalf("expected the removed website configuration to leave the state, got: %v", state)
+	}
+}
@@ -1,10 +1,20 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io"
 	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/minio/minio-go"
+	"github.com/minio/minio-go/pkg/credentials"
 )
 
 type Config struct {
@@ -13,13 +23,61 @@ type Config struct {
 	s3_access_key string
 	s3_secret_key string
 	api_signature string
+	bucket_lookup string
+	session_token string
+	profile       string
+	alias         string
+	assume_role   *assumeRoleConfig
 	ssl           bool
+	ssl_set       bool
 	debug         bool
+
+	ca_cert_file         string
+	ca_cert_pem          string
+	client_cert          string
+	client_key           string
+	tls_server_name      string
+	insecure_skip_verify bool
+
+	http_proxy              string
+	no_proxy                string
+	connect_timeout         time.Duration
+	request_timeout         time.Duration
+	idle_conn_timeout       time.Duration
+	max_idle_conns_per_host int
+
+	trace_http bool
+	trace_file string
+
+	max_retries       int
+	retry_min_backoff time.Duration
+	retry_max_backoff time.Duration
+}
+
+type assumeRoleConfig struct {
+	role_arn     string
+	session_name string
+	duration     int
+	external_id  string
+	sts_endpoint string
 }
 
 type s3Client struct {
 	region   string
 	s3Client *minio.Client
+	trace    io.Writer
+	stopCtx  context.Context
+
+	// Used by bucketRequest for the subresources minio has no API for.
+	endpoint      string
+	secure        bool
+	creds         *credentials.Credentials
+	bucket_lookup string
+	httpClient    *http.Client
+
+	max_retries       int
+	retry_min_backoff time.Duration
+	retry_max_backoff time.Duration
 }
 
 func (c *Config) NewClient() (interface{}, error) {
@@ -34,6 +92,19 @@ func (c *Config) NewClient() (interface{}, error) {
 		log.Println("[FATAL] S3 Server undefined!")
 		return nil, errors.New("S3 Server not defined!")
 	}
+	host, secure, err := parseS3Server(c.s3_server)
+	if err != nil {
+		log.Printf("[FATAL] %v", err)
+		return nil, err
+	}
+	if secure != nil {
+		if c.ssl_set && c.ssl != *secure {
+			log.Printf("[FATAL] S3 Server [%s] contradicts s3_ssl = %v", c.s3_server, c.ssl)
+			return nil, errors.New(fmt.Sprintf("S3 Server [%s] contradicts s3_ssl = %v.  Remove s3_ssl or change the URL scheme", c.s3_server, c.ssl))
+		}
+		c.ssl = *secure
+	}
+	c.s3_server = host
 	if c.debug {
 		log.Printf("[DEBUG] S3 Server: [%s]", c.s3_server)
 	}
@@ -51,20 +122,31 @@ func (c *Config) NewClient() (interface{}, error) {
 
 	// S3 Access Key
 	if len(c.s3_access_key) < 1 {
-		log.Println("[FATAL] S3 Access Key not defined!")
-		return nil, errors.New("S3 Access Key not defined!")
-	}
-	if c.debug {
+		if c.debug {
+			log.Println("[DEBUG] S3 Access Key not defined.  Using the credential provider chain")
+		}
+	} else if c.debug {
 		log.Printf("[DEBUG] S3 Access Key: [%s]", c.s3_access_key)
 	}
 
 	// S3 Secret Key
-	if len(c.s3_secret_key) < 1 {
+	if len(c.s3_access_key) > 0 && len(c.s3_secret_key) < 1 {
 		log.Println("[FATAL] S3 Secret Key not defined!")
 		return nil, errors.New("S3 Secret Key not defined!")
 	}
+	if c.debug && len(c.s3_secret_key) > 0 {
+		log.Printf("[DEBUG] S3 Secret Key: [%s]", redactSecret(c.s3_secret_key))
+	}
+
+	// Session Token
+	if c.debug && len(c.session_token) > 0 {
+		log.Printf("[DEBUG] S3 Session Token: [%s]", redactSecret(c.session_token))
+	}
+
+	// Shared credentials
 	if c.debug {
-		log.Printf("[DEBUG] S3 Secret Key: [%s]", c.s3_secret_key)
+		log.Printf("[DEBUG] AWS Profile: [%s]", c.profile)
+		log.Printf("[DEBUG] Minio Client Alias: [%s]", c.alias)
 	}
 
 	// API Signature
@@ -83,16 +165,74 @@ func (c *Config) NewClient() (interface{}, error) {
 		log.Printf("[DEBUG] SSL: %v", c.ssl)
 	}
 
-	// Initialize minio client object.
-	minioClient := new(minio.Client)
-	var err error
-	if c.api_signature == "v2" {
-		minioClient, err = minio.NewV2(c.s3_server, c.s3_access_key, c.s3_secret_key, c.ssl)
-	} else if c.api_signature == "v4" {
-		minioClient, err = minio.NewV4(c.s3_server, c.s3_access_key, c.s3_secret_key, c.ssl)
-	} else {
-		minioClient, err = minio.New(c.s3_server, c.s3_access_key, c.s3_secret_key, c.ssl)
+	// HTTP Transport
+	transport, err := c.newTransport()
+	if err != nil {
+		log.Printf("[FATAL] Unable to configure the HTTP transport.  Error: %v", err)
+		return nil, err
+	}
+
+	// Assume Role
+	creds := c.credentialChain()
+	if c.assume_role != nil {
+		if len(c.assume_role.sts_endpoint) < 1 {
+			scheme := "http"
+			if c.ssl {
+				scheme = "https"
+			}
+			c.assume_role.sts_endpoint = fmt.Sprintf("%s://%s/", scheme, c.s3_server)
+		}
+		if c.debug {
+			log.Printf("[DEBUG] Assume Role: [%s], Session Name: [%s], Duration: [%d], STS Endpoint: [%s]",
+				c.assume_role.role_arn, c.assume_role.session_name, c.assume_role.duration,
+				c.assume_role.sts_endpoint)
+		}
+		creds = credentials.New(&assumeRoleProvider{
+			base:         creds,
+			endpoint:     c.assume_role.sts_endpoint,
+			region:       c.s3_region,
+			role_arn:     c.assume_role.role_arn,
+			session_name: c.assume_role.session_name,
+			external_id:  c.assume_role.external_id,
+			duration:     time.Duration(c.assume_role.duration) * time.Second,
+			client:       &http.Client{Transport: transport, Timeout: 30 * time.Second},
+			debug:        c.debug,
+		})
+	}
+
+	// Retries
+	if c.retry_max_backoff < c.retry_min_backoff {
+		log.Println("[FATAL] retry_max_backoff is lower than retry_min_backoff")
+		return nil, errors.New("retry_max_backoff must not be lower than retry_min_backoff")
+	}
+	if c.debug {
+		log.Printf("[DEBUG] Max Retries: [%d], Retry Backoff: [%s - %s]",
+			c.max_retries, c.retry_min_backoff, c.retry_max_backoff)
 	}
+
+	// Bucket Lookup
+	var lookup minio.BucketLookupType
+	switch c.bucket_lookup {
+	case "", "auto":
+		lookup = minio.BucketLookupAuto
+	case "dns":
+		lookup = minio.BucketLookupDNS
+	case "path":
+		lookup = minio.BucketLookupPath
+	default:
+		log.Printf("[FATAL] Invalid bucket lookup [%s]", c.bucket_lookup)
+		return nil, errors.New(fmt.Sprintf("Invalid bucket lookup [%s].  Valid values: auto, dns, path", c.bucket_lookup))
+	}
+	if c.debug {
+		log.Printf("[DEBUG] Bucket Lookup: [%s]", c.bucket_lookup)
+	}
+
+	// Initialize minio client object.
+	minioClient, err := minio.NewWithOptions(c.s3_server, &minio.Options{
+		Creds:        creds,
+		Secure:       c.ssl,
+		BucketLookup: lookup,
+	})
 	if err != nil {
 		log.Println("[FATAL] Error connecting to S3 server.")
 		return nil, err
@@ -101,9 +241,176 @@ func (c *Config) NewClient() (interface{}, error) {
 			log.Printf("[DEBUG] S3 client initialized")
 		}
 	}
+	minioClient.SetCustomTransport(transport)
+
+	// HTTP Tracing
+	var trace io.Writer
+	if c.trace_http {
+		if len(c.trace_file) > 0 {
+			trace, err = newTraceFile(c.trace_file)
+			if err != nil {
+				log.Printf("[FATAL] %v", err)
+				return nil, err
+			}
+		} else {
+			trace = logTraceWriter{}
+		}
+		if c.debug {
+			log.Printf("[DEBUG] HTTP Tracing enabled.  Trace File: [%s]", c.trace_file)
+		}
+	}
 
 	return &s3Client{
 		region:   c.s3_region,
 		s3Client: minioClient,
+		trace:    trace,
+
+		endpoint:      c.s3_server,
+		secure:        c.ssl,
+		creds:         creds,
+		bucket_lookup: c.bucket_lookup,
+		httpClient:    &http.Client{Transport: transport},
+
+		max_retries:       c.max_retries,
+		retry_min_backoff: c.retry_min_backoff,
+		retry_max_backoff: c.retry_max_backoff,
 	}, nil
 }
+
+// credentialChain returns the provider chain used to sign requests.  Keys set in
+// the provider configuration take precedence, followed by the environment,
+// the shared AWS credentials file, the Minio client configuration and
+// finally the EC2 instance role.
+func (c *Config) credentialChain() *credentials.Credentials {
+	signer := credentials.SignatureV4
+	if c.api_signature == "v2" {
+		signer = credentials.SignatureV2
+	}
+	return credentials.NewChainCredentials([]credentials.Provider{
+		&credentials.Static{
+			Value: credentials.Value{
+				AccessKeyID:     c.s3_access_key,
+				SecretAccessKey: c.s3_secret_key,
+				SessionToken:    c.session_token,
+				SignerType:      signer,
+			},
+		},
+		&signerProvider{credentials.NewEnvAWS(), signer},
+		&signerProvider{credentials.NewEnvMinio(), signer},
+		&signerProvider{credentials.NewFileAWSCredentials("", c.profile), signer},
+		&signerProvider{credentials.NewFileMinioClient("", c.alias), signer},
+		&signerProvider{credentials.New(newIAMProvider()), signer},
+	})
+}
+
+// signerProvider adapts a *credentials.Credentials to the
+// credentials.Provider interface expected by the chain and applies the
+// configured API signature to whatever it retrieves.
+type signerProvider struct {
+	creds  *credentials.Credentials
+	signer credentials.SignatureType
+}
+
+func (p *signerProvider) Retrieve() (credentials.Value, error) {
+	value, err := p.creds.Get()
+	if err != nil {
+		return value, err
+	}
+	registerSecret(value.SecretAccessKey)
+	registerSecret(value.SessionToken)
+	if !value.SignerType.IsAnonymous() {
+		value.SignerType = p.signer
+	}
+	return value, nil
+}
+
+func (p *signerProvider) IsExpired() bool {
+	return p.creds.IsExpired()
+}
+
+// The EC2 instance metadata service is asked for credentials without a proxy
+// and for no longer than iamTimeout, so a machine outside of EC2 is not held
+// up waiting for it.
+var (
+	iamTimeout                     = 2 * time.Second
+	iamTransport http.RoundTripper = &http.Transport{
+		DialContext: (&net.Dialer{Timeout: iamTimeout}).DialContext,
+	}
+)
+
+// iamProvider retrieves the credentials of the EC2 instance role.  When the
+// metadata service cannot be reached that is remembered, so the credential
+// chain, which is consulted for every request until it finds credentials,
+// does not wait for it again.
+type iamProvider struct {
+	iam         *credentials.IAM
+	unreachable error
+}
+
+func newIAMProvider() *iamProvider {
+	return &iamProvider{
+		iam: &credentials.IAM{
+			Client: &http.Client{Transport: iamTransport, Timeout: iamTimeout},
+		},
+	}
+}
+
+func (p *iamProvider) Retrieve() (credentials.Value, error) {
+	if p.unreachable != nil {
+		return credentials.Value{}, p.unreachable
+	}
+	value, err := p.iam.Retrieve()
+	if _, ok := err.(*url.Error); ok {
+		log.Printf("[DEBUG] EC2 instance metadata service unreachable, skipping instance role credentials.  Error: %v", err)
+		p.unreachable = err
+	}
+	return value, err
+}
+
+func (p *iamProvider) IsExpired() bool {
+	return p.iam.IsExpired()
+}
+
+// parseS3Server accepts either a bare host[:port] or a full http(s) URL and
+// returns the host[:port] expected by minio.  When a URL scheme is present the
+// returned bool pointer reports whether it asks for TLS.
+func parseS3Server(server string) (string, *bool, error) {
+	if !strings.Contains(server, "://") {
+		if strings.ContainsAny(server, "/?#") {
+			return "", nil, errors.New(fmt.Sprintf("Invalid S3 Server [%s].  Expected host[:port] or http(s)://host[:port]", server))
+		}
+		return server, nil, nil
+	}
+
+	u, err := url.Parse(server)
+	if err != nil {
+		return "", nil, errors.New(fmt.Sprintf("Invalid S3 Server [%s].  Error: %v", server, err))
+	}
+
+	var secure bool
+	switch strings.ToLower(u.Scheme) {
+	case "http":
+		secure = false
+	case "https":
+		secure = true
+	default:
+		return "", nil, errors.New(fmt.Sprintf("Invalid S3 Server [%s].  Unsupported scheme [%s], use http or https", server, u.Scheme))
+	}
+
+	if len(u.Host) < 1 {
+		return "", nil, errors.New(fmt.Sprintf("Invalid S3 Server [%s].  Missing host", server))
+	}
+	if u.User != nil {
+		return "", nil, errors.New(fmt.Sprintf("Invalid S3 Server [%s].  Credentials must not be part of the URL", server))
+	}
+	if (len(u.Path) > 0 && u.Path != "/") || len(u.RawQuery) > 0 || len(u.Fragment) > 0 {
+		return "", nil, errors.New(fmt.Sprintf("Invalid S3 Server [%s].  Paths, queries and fragments are not supported", server))
+	}
+	if len(u.Port()) > 0 {
+		if port, err := strconv.Atoi(u.Port()); err != nil || port < 1 || port > 65535 {
+			return "", nil, errors.New(fmt.Sprintf("Invalid S3 Server [%s].  Invalid port [%s]", server, u.Port()))
+		}
+	}
+
+	return u.Host, &secure, nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/minio/minio-go"
+)
+
+// Default timeouts for the operations of every resource.  They can be
+// overridden with a timeouts block in the resource configuration.
+var defaultResourceTimeouts = &schema.ResourceTimeout{
+	Create: schema.DefaultTimeout(10 * time.Minute),
+	Read:   schema.DefaultTimeout(5 * time.Minute),
+	Update: schema.DefaultTimeout(10 * time.Minute),
+	Delete: schema.DefaultTimeout(10 * time.Minute),
+}
+
+// operationContext returns the context for one resource operation.  It
+// expires after the operation timeout and is cancelled as soon as Terraform
+// asks the provider to stop, e.g. on Ctrl-C.
+func (c *s3Client) operationContext(d *schema.ResourceData, timeout string) (context.Context, context.CancelFunc) {
+	parent := c.stopCtx
+	if parent == nil {
+		parent = context.Background()
+	}
+	return context.WithTimeout(parent, d.Timeout(timeout))
+}
+
+// removeObject deletes a single object through the multi-object delete API so
+// the request can be cancelled with ctx.
+func removeObject(ctx context.Context, s3_client *minio.Client, bucket, name string) error {
+	objectsCh := make(chan string, 1)
+	objectsCh <- name
+	close(objectsCh)
+	for removeErr := range s3_client.RemoveObjectsWithContext(ctx, bucket, objectsCh) {
+		if removeErr.Err != nil {
+			return removeErr.Err
+		}
+	}
+	return ctx.Err()
+}
+
+// abortUpload cleans up the multipart session left behind by an upload that
+// was cancelled or timed out.  minio only tries to abort it with the already
+// cancelled context, which never reaches the server.
+func abortUpload(ctx context.Context, s3_client *minio.Client, bucket, name string) {
+	if ctx.Err() == nil {
+		return
+	}
+	log.Printf("[WARN] Upload of object [%s] to bucket [%s] interrupted, aborting incomplete upload", name, bucket)
+	if err := s3_client.RemoveIncompleteUpload(bucket, name); err != nil {
+		log.Printf("[WARN] Unable to abort incomplete upload of object [%s] to bucket [%s].  Error: %v", name, bucket, err)
+	}
+}
@@ -1,8 +1,14 @@
 package main
 
 import (
+	"crypto/md5"
+	"encoding/hex"
 	"errors"
+	"io"
 	"log"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/terraform/helper/schema"
 	"fmt"
@@ -16,6 +22,14 @@ func resourceS3File() *schema.Resource {
 		Update: resourceS3FileUpdate,
 		Delete: resourceS3FileDelete,
 
+		Timeouts: defaultResourceTimeouts,
+
+		Importer: &schema.ResourceImporter{
+			State: resourceS3FileImport,
+		},
+
+		CustomizeDiff: resourceS3FileCustomizeDiff,
+
 		Schema: map[string]*schema.Schema{
 			"bucket": {
 				Type:     schema.TypeString,
@@ -39,6 +53,22 @@ func resourceS3File() *schema.Resource {
 				Optional: true,
 				Default:  true,
 			},
+			"etag": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"size": {
+				Type:     schema.TypeInt,
+				Computed: true,
+			},
+			"last_modified": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"version_id": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
 		},
 	}
 }
@@ -49,16 +79,22 @@ func resourceS3FileCreate(d *schema.ResourceData, meta interface{}) error {
 	name := d.Get("name").(string)
 	file_path := d.Get("file_path").(string)
 	content_type := d.Get("content_type").(string)
-	s3_client := meta.(*s3Client).s3Client
+	s3_client := meta.(*s3Client).clientFor("s3_file.create", bucket+"/"+name)
+	ctx, cancel := meta.(*s3Client).operationContext(d, schema.TimeoutCreate)
+	defer cancel()
 
 	if debug {
 		log.Printf("[DEBUG] Creating object [%s] from file [%s] in bucket [%s]",
 			name, file_path, bucket)
 	}
 
-	_, err := s3_client.FPutObject(bucket, name, file_path,
-		minio.PutObjectOptions{ContentType: content_type})
+	err := meta.(*s3Client).retry(ctx, "s3_file.create", true, func() error {
+		_, err := s3_client.FPutObjectWithContext(ctx, bucket, name, file_path,
+			minio.PutObjectOptions{ContentType: content_type})
+		return err
+	})
 	if err != nil {
+		abortUpload(ctx, s3_client, bucket, name)
 		log.Printf("[FATAL] Unable to create object [%s]. Error: %v", name, err)
 		return errors.New(fmt.Sprintf("Unable to create object [%s].  Error: %v", name, err))
 	}
@@ -68,48 +104,179 @@ func resourceS3FileCreate(d *schema.ResourceData, meta interface{}) error {
 			name, file_path, bucket)
 	}
 
-	return nil
+	d.SetId(fmt.Sprintf("%s/%s", bucket, name))
+	return resourceS3FileRead(d, meta)
 }
 
 func resourceS3FileRead(d *schema.ResourceData, meta interface{}) error {
 	debug := d.Get("debug").(bool)
 	bucket := d.Get("bucket").(string)
 	name := d.Get("name").(string)
-	file_path := d.Get("file_path").(string)
-	s3_client := meta.(*s3Client).s3Client
+	s3_client := meta.(*s3Client).clientFor("s3_file.read", bucket+"/"+name)
+	ctx, cancel := meta.(*s3Client).operationContext(d, schema.TimeoutRead)
+	defer cancel()
 
 	if debug {
-		log.Printf("[DEBUG] Reading file [%s] from bucket [%s] into file [%s]", name, bucket, file_path)
+		log.Printf("[DEBUG] Reading file [%s] from bucket [%s]", name, bucket)
 	}
 
-	err := s3_client.FGetObject(bucket, name, file_path, minio.GetObjectOptions{})
+	var info minio.ObjectInfo
+	err := meta.(*s3Client).retry(ctx, "s3_file.read", true, func() (err error) {
+		info, err = s3_client.StatObject(bucket, name, minio.StatObjectOptions{})
+		return err
+	})
 	if err != nil {
-		log.Printf("[FATAL]  Unable to read file [%s] from bucket [%s] into file [%s].  Error: %v", name, bucket, file_path, err)
+		if isGone(err) {
+			log.Printf("[WARN] File [%s] not found in bucket [%s], removing from state", name, bucket)
+			d.SetId("")
+			return nil
+		}
+		log.Printf("[FATAL]  Unable to read file [%s] from bucket [%s].  Error: %v", name, bucket, err)
 		return errors.New(fmt.Sprintf("Unable to read file [%s].  Error: %v", name, err))
 	}
 
+	d.Set("content_type", info.ContentType)
+	d.Set("etag", info.ETag)
+	d.Set("size", info.Size)
+	d.Set("last_modified", info.LastModified.Format(time.RFC3339))
+	d.Set("version_id", info.Metadata.Get("X-Amz-Version-Id"))
+
 	if debug {
-		log.Printf("[DEBUG] Read file [%s] from bucket [%s]", name, bucket)
+		log.Printf("[DEBUG] Read file [%s] from bucket [%s].  ETag: [%s], Size: [%d]", name, bucket, info.ETag, info.Size)
+	}
+	return nil
+}
+
+// resourceS3FileCustomizeDiff plans an update whenever the ETag recorded by
+// Read no longer matches the MD5 of the local file, either because the local
+// file changed or because the object was replaced in the bucket.
+func resourceS3FileCustomizeDiff(d *schema.ResourceDiff, meta interface{}) error {
+	if d.Id() == "" {
+		return nil
+	}
+	etag := d.Get("etag").(string)
+	// Multipart uploads have an ETag of the form MD5SUM-N which can not be
+	// compared against the MD5 of the whole file.
+	if etag == "" || strings.Contains(etag, "-") {
+		return nil
+	}
+	// The file may be computed or only written by another resource during
+	// apply.  Apply uploads whatever is there then.
+	if !d.NewValueKnown("file_path") {
+		return nil
+	}
+	file_path := d.Get("file_path").(string)
+	sum, err := fileMD5(file_path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.New(fmt.Sprintf("Unable to hash file [%s].  Error: %v", file_path, err))
+	}
+	if sum != etag {
+		if d.Get("debug").(bool) {
+			log.Printf("[DEBUG] File [%s] hash [%s] differs from object ETag [%s]", file_path, sum, etag)
+		}
+		return d.SetNew("etag", sum)
 	}
 	return nil
 }
 
+// fileMD5 returns the hex encoded MD5 sum of the file at file_path.
+func fileMD5(file_path string) (string, error) {
+	f, err := os.Open(file_path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
 func resourceS3FileUpdate(d *schema.ResourceData, meta interface{}) error {
-	return resourceS3BucketCreate(d, meta)
+	debug := d.Get("debug").(bool)
+	old_bucket, new_bucket := d.GetChange("bucket")
+	old_name, new_name := d.GetChange("name")
+	bucket := new_bucket.(string)
+	name := new_name.(string)
+	file_path := d.Get("file_path").(string)
+	content_type := d.Get("content_type").(string)
+	s3_client := meta.(*s3Client).clientFor("s3_file.update", bucket+"/"+name)
+	ctx, cancel := meta.(*s3Client).operationContext(d, schema.TimeoutUpdate)
+	defer cancel()
+
+	moved := d.HasChange("bucket") || d.HasChange("name")
+
+	if d.HasChange("file_path") || d.HasChange("content_type") || d.HasChange("etag") {
+		if debug {
+			log.Printf("[DEBUG] Updating object [%s] from file [%s] in bucket [%s]", name, file_path, bucket)
+		}
+		err := meta.(*s3Client).retry(ctx, "s3_file.update", true, func() error {
+			_, err := s3_client.FPutObjectWithContext(ctx, bucket, name, file_path,
+				minio.PutObjectOptions{ContentType: content_type})
+			return err
+		})
+		if err != nil {
+			abortUpload(ctx, s3_client, bucket, name)
+			log.Printf("[FATAL] Unable to update object [%s]. Error: %v", name, err)
+			return errors.New(fmt.Sprintf("Unable to update object [%s].  Error: %v", name, err))
+		}
+	} else if moved {
+		if debug {
+			log.Printf("[DEBUG] Copying object [%s] in bucket [%s] to [%s] in bucket [%s]",
+				old_name, old_bucket, name, bucket)
+		}
+		dst, err := minio.NewDestinationInfo(bucket, name, nil, nil)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Unable to copy object [%s] to [%s].  Error: %v", old_name, name, err))
+		}
+		src := minio.NewSourceInfo(old_bucket.(string), old_name.(string), nil)
+		err = meta.(*s3Client).retry(ctx, "s3_file.update", true, func() error {
+			return s3_client.CopyObject(dst, src)
+		})
+		if err != nil {
+			log.Printf("[FATAL] Unable to copy object [%s] in bucket [%s] to [%s] in bucket [%s].  Error: %v",
+				old_name, old_bucket, name, bucket, err)
+			return errors.New(fmt.Sprintf("Unable to copy object [%s] to [%s].  Error: %v", old_name, name, err))
+		}
+	}
+
+	if moved {
+		if debug {
+			log.Printf("[DEBUG] Removing previous object [%s] from bucket [%s]", old_name, old_bucket)
+		}
+		err := meta.(*s3Client).retry(ctx, "s3_file.update", true, func() error {
+			return removeObject(ctx, s3_client, old_bucket.(string), old_name.(string))
+		})
+		if err != nil && !isGone(err) {
+			log.Printf("[FATAL] Unable to delete file [%s] from bucket [%s].  Error: %v", old_name, old_bucket, err)
+			return errors.New(fmt.Sprintf("Unable to delete file [%s] from bucket [%s].  Error: %v", old_name, old_bucket, err))
+		}
+		d.SetId(fmt.Sprintf("%s/%s", bucket, name))
+	}
+
+	return resourceS3FileRead(d, meta)
 }
 
 func resourceS3FileDelete(d *schema.ResourceData, meta interface{}) error {
 	debug := d.Get("debug").(bool)
 	bucket := d.Get("bucket").(string)
 	name := d.Get("name").(string)
-	s3_client := meta.(*s3Client).s3Client
+	s3_client := meta.(*s3Client).clientFor("s3_file.delete", bucket+"/"+name)
+	ctx, cancel := meta.(*s3Client).operationContext(d, schema.TimeoutDelete)
+	defer cancel()
 
 	if debug {
 		log.Printf("[DEBUG] Deleting file [%s] from bucket [%s]", name, bucket)
 	}
 
-	err := s3_client.RemoveObject(bucket, name)
-	if err != nil {
+	err := meta.(*s3Client).retry(ctx, "s3_file.delete", true, func() error {
+		return removeObject(ctx, s3_client, bucket, name)
+	})
+	if err != nil && !isGone(err) {
 		log.Printf("[FATAL] Unable to delete file [%s] from bucket [%s].  Error: %v", name, bucket, err)
 		return errors.New(fmt.Sprintf("Unable to delete file [%s] from bucket [%s].  Error: %v", name, bucket, err))
 	}
@@ -119,3 +286,26 @@ func resourceS3FileDelete(d *schema.ResourceData, meta interface{}) error {
 	}
 	return nil
 }
+
+func resourceS3FileImport(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	bucket, name, err := parseS3ObjectId(d.Id())
+	if err != nil {
+		return nil, err
+	}
+	s3_client := meta.(*s3Client).clientFor("s3_file.import", bucket+"/"+name)
+	ctx, cancel := meta.(*s3Client).operationContext(d, schema.TimeoutRead)
+	defer cancel()
+
+	err = meta.(*s3Client).retry(ctx, "s3_file.import", true, func() error {
+		_, err := s3_client.StatObject(bucket, name, minio.StatObjectOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Unable to import file [%s] from bucket [%s].  Error: %v", name, bucket, err))
+	}
+
+	d.Set("bucket", bucket)
+	d.Set("name", name)
+	d.Set("debug", true)
+	return []*schema.ResourceData{d}, nil
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/terraform/config"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// testResourceConfig builds the configuration Terraform would pass to a
+// provider for the given attributes.
+func testResourceConfig(t *testing.T, raw map[string]interface{}) *terraform.ResourceConfig {
+	c, err := config.NewRawConfig(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return terraform.NewResourceConfig(c)
+}
+
+func TestResourceS3FileCustomizeDiffUnknownFile(t *testing.T) {
+	state := &terraform.InstanceState{
+		ID: "my-bucket/file.txt",
+		Attributes: map[string]string{
+			"bucket":       "my-bucket",
+			"name":         "file.txt",
+			"file_path":    "file.txt",
+			"content_type": "application/octet-stream",
+			"debug":        "false",
+			"etag":         "d41d8cd98f00b204e9800998ecf8427e",
+		},
+	}
+	cases := map[string]string{
+		"missing file":  filepath.Join(t.TempDir(), "file.txt"),
+		"computed path": config.UnknownVariableValue,
+	}
+	for name, file_path := range cases {
+		c := testResourceConfig(t, map[string]interface{}{
+			"bucket":    "my-bucket",
+			"name":      "file.txt",
+			"file_path": file_path,
+			"debug":     false,
+		})
+		diff, err := resourceS3File().Diff(state, c, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if diff == nil || diff.Attributes["file_path"] == nil {
+			t.Fatalf("%s: expected a file_path change, got: %v", name, diff)
+		}
+		if attr := diff.Attributes["etag"]; attr != nil {
+			t.Fatalf("%s: expected no etag change, got: %#v", name, attr)
+		}
+	}
+}
+
+// testFile writes content to a file in a temporary directory and returns
+// its path.
+func testFile(t *testing.T, name, content string) string {
+	file_path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(file_path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file_path
+}
+
+func TestResourceS3File(t *testing.T) {
+	s := newFakeS3(t)
+	s.putBucket("my-bucket", "")
+	meta := s.meta(t, nil)
+	r := resourceS3File()
+	file_path := testFile(t, "file.txt", "content")
+
+	// Create
+	raw := map[string]interface{}{
+		"bucket":       "my-bucket",
+		"name":         "file.txt",
+		"file_path":    file_path,
+		"content_type": "text/plain",
+		"debug":        false,
+	}
+	state := testApply(t, r, nil, raw, meta)
+	testCheckAttributes(t, state, map[string]string{
+		"id":           "my-bucket/file.txt",
+		"content_type": "text/plain",
+		"etag":         "9a0364b9e99bb480dd25e1f0284c8555",
+		"size":         "7",
+	})
+	if object := s.object("my-bucket", "file.txt"); object == nil || string(object.data) != "content" {
+		t.Fatalf("expected the file to be uploaded, got: %v", object)
+	}
+	testCheckNoPlan(t, r, state, raw, meta)
+
+	// Drift
+	s.putObject("my-bucket", "file.txt", "text/plain", []byte("changed"))
+	if diff := testPlan(t, r, state, raw, meta); diff == nil || diff.Attributes["etag"] == nil {
+		t.Fatalf("expected the replaced object to be uploaded again, got: %v", diff)
+	}
+	state = testApply(t, r, testRefresh(t, r, state, meta), raw, meta)
+	if object := s.object("my-bucket", "file.txt"); string(object.data) != "content" {
+		t.Fatalf("expected the object to be restored, got: %q", object.data)
+	}
+	testCheckNoPlan(t, r, state, raw, meta)
+
+	// Import
+	imported := testImport(t, r, "my-bucket/file.txt", meta)
+	testCheckAttributes(t, imported, map[string]string{
+		"bucket": "my-bucket",
+		"name":   "file.txt",
+		"etag":   "9a0364b9e99bb480dd25e1f0284c8555",
+	})
+
+	// Destroy
+	testDestroy(t, r, state, meta)
+	if s.object("my-bucket", "file.txt") != nil {
+		t.Fatal("expected the object to be removed")
+	}
+	if state := testRefresh(t, r, state, meta); state != nil {
+		t.Fatalf("expected the removed object to leave the state, got: %v", state)
+	}
+}
+
+func TestResourceS3FileUpdate(t *testing.T) {
+	s := newFakeS3(t)
+	s.putBucket("my-bucket", "")
+	s.putBucket("other-bucket", "")
+	meta := s.meta(t, nil)
+	r := resourceS3File()
+	file_path := testFile(t, "file.txt", "content")
+	raw := map[string]interface{}{
+		"bucket":       "my-bucket",
+		"name":         "file.txt",
+		"file_path":    file_path,
+		"content_type": "text/plain",
+		"debug":        false,
+	}
+	state := testApply(t, r, nil, raw, meta)
+
+	// Content change
+	if err := ioutil.WriteFile(file_path, []byte("new content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	state = testApply(t, r, state, raw, meta)
+	if object := s.object("my-bucket", "file.txt"); string(object.data) != "new content" {
+		t.Fatalf("expected the new content to be uploaded, got: %q", object.data)
+	}
+	testCheckAttributes(t, state, map[string]string{"id": "my-bucket/file.txt", "size": "11"})
+	testCheckNoPlan(t, r, state, raw, meta)
+
+	// Content type change
+	raw["content_type"] = "application/json"
+	state = testApply(t, r, state, raw, meta)
+	if object := s.object("my-bucket", "file.txt"); object.content_type != "application/json" {
+		t.Fatalf("expected the content type to be updated, got: %s", object.content_type)
+	}
+	testCheckAttributes(t, state, map[string]string{"content_type": "application/json"})
+	testCheckNoPlan(t, r, state, raw, meta)
+
+	// Move
+	raw["bucket"] = "other-bucket"
+	raw["name"] = "moved.txt"
+	state = testApply(t, r, state, raw, meta)
+	if s.object("my-bucket", "file.txt") != nil {
+		t.Fatal("expected the previous object to be removed")
+	}
+	if object := s.object("other-bucket", "moved.txt"); object == nil || string(object.data) != "new content" {
+		t.Fatalf("expected the object to be copied, got: %v", object)
+	}
+	if n := s.count("PUT /other-bucket/moved.txt"); n != 1 {
+		t.Fatalf("expected 1 copy request, got %d", n)
+	}
+	testCheckAttributes(t, state, map[string]string{"id": "other-bucket/moved.txt"})
+	testCheckNoPlan(t, r, state, raw, meta)
+
+	// Move and content change
+	if err := ioutil.WriteFile(file_path, []byte("moved content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	raw["name"] = "moved-again.txt"
+	state = testApply(t, r, state, raw, meta)
+	if s.object("other-bucket", "moved.txt") != nil {
+		t.Fatal("expected the previous object to be removed")
+	}
+	if object := s.object("other-bucket", "moved-again.txt"); object == nil || string(object.data) != "moved content" {
+		t.Fatalf("expected the new content to be uploaded, got: %v", object)
+	}
+	testCheckAttributes(t, state, map[string]string{"id": "other-bucket/moved-again.txt", "size": "13"})
+	testCheckNoPlan(t, r, state, raw, meta)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"io"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const redacted = "[REDACTED]"
+
+// Known secrets are replaced wherever they show up in the log output, on top
+// of the patterns below which catch signatures, tokens and SSE-C keys that
+// the provider never sees directly (e.g. in HTTP traces).
+var (
+	secretsMu sync.RWMutex
+	secrets   = map[string]bool{}
+
+	redactPatterns = []*regexp.Regexp{
+		// Presigned URL query parameters and Authorization headers (v2 and v4).
+		regexp.MustCompile(`(?i)(X-Amz-Signature=)[^&\s"]+`),
+		regexp.MustCompile(`(?i)(Signature=)[^&\s",]+`),
+		regexp.MustCompile(`(?i)(Authorization:\s*AWS\s+[^:\s]+:)\S+`),
+		// Session tokens as query parameters or headers.
+		regexp.MustCompile(`(?i)(X-Amz-Security-Token[=:]\s*)[^&\s"]+`),
+		// The code of the MFA device, after its serial number.
+		regexp.MustCompile(`(?i)(X-Amz-Mfa:[ \t]*\S+[ \t]+)\S+`),
+		// SSE-C keys, including the copy source variant.
+		regexp.MustCompile(`(?i)(server-side-encryption-customer-key[=:]\s*)[^&\s"]+`),
+	}
+)
+
+// registerSecret makes sure value never appears in the provider logs.
+func registerSecret(value string) {
+	if len(value) < 1 {
+		return
+	}
+	secretsMu.Lock()
+	secrets[value] = true
+	secretsMu.Unlock()
+}
+
+// redact masks every registered secret and every known secret pattern in s.
+func redact(s string) string {
+	secretsMu.RLock()
+	for secret := range secrets {
+		s = strings.Replace(s, secret, redacted, -1)
+	}
+	secretsMu.RUnlock()
+	for _, pattern := range redactPatterns {
+		s = pattern.ReplaceAllString(s, "${1}"+redacted)
+	}
+	return s
+}
+
+// redactSecret describes a secret for debug output without revealing it.
+func redactSecret(value string) string {
+	if len(value) < 1 {
+		return ""
+	}
+	return redacted
+}
+
+// redactingWriter passes everything written to it through redact.
+type redactingWriter struct {
+	out io.Writer
+}
+
+func (w *redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.out, redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+var redactLogsMu sync.Mutex
+
+// redactLogs routes the standard logger through a redactingWriter.  It is
+// safe to call more than once.
+func redactLogs() {
+	redactLogsMu.Lock()
+	defer redactLogsMu.Unlock()
+	if _, ok := log.Writer().(*redactingWriter); ok {
+		return
+	}
+	log.SetOutput(&redactingWriter{out: log.Writer()})
+}
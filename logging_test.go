@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/minio/minio-go"
+	"github.com/minio/minio-go/pkg/encrypt"
+)
+
+// testLogBuffer collects the provider log for the duration of a test.
+type testLogBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *testLogBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *testLogBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestLogRedaction(t *testing.T) {
+	logs := &testLogBuffer{}
+	output := log.Writer()
+	log.SetOutput(logs)
+	t.Cleanup(func() { log.SetOutput(output) })
+
+	// Record the signatures the server receives so the log can be checked
+	// for them.
+	var mu sync.Mutex
+	var signatures []string
+	s := &fakeS3{buckets: map[string]*fakeBucket{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if i := strings.Index(auth, "Signature="); i >= 0 {
+			mu.Lock()
+			signatures = append(signatures, auth[i+len("Signature="):])
+			mu.Unlock()
+		}
+		s.ServeHTTP(w, r)
+	}))
+	t.Cleanup(s.Close)
+
+	meta := s.meta(t, map[string]interface{}{
+		"session_token": "fake-s3-session-token",
+		"s3_debug":      true,
+		"trace_http":    true,
+	})
+	bucket := testApply(t, resourceS3Bucket(), nil, map[string]interface{}{
+		"bucket":     "my-bucket",
+		"debug":      true,
+		"versioning": []interface{}{map[string]interface{}{"enabled": true}},
+	}, meta)
+	object := testApply(t, resourceS3Object(), nil, map[string]interface{}{
+		"bucket":  "my-bucket",
+		"name":    "object.txt",
+		"content": "content",
+		"debug":   true,
+	}, meta)
+
+	// The provider has no SSE-C support of its own, but its traces include the
+	// headers of whatever requests the client sends.
+	key := []byte("0123456789abcdef0123456789abcdef")
+	sse, err := encrypt.NewSSEC(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := meta.(*s3Client).clientFor("s3_object.create", "my-bucket/encrypted.txt")
+	_, err = client.PutObject("my-bucket", "encrypted.txt", strings.NewReader("secret"), 6, minio.PutObjectOptions{ServerSideEncryption: sse})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.StatObject("my-bucket", "encrypted.txt", minio.StatObjectOptions{GetObjectOptions: minio.GetObjectOptions{ServerSideEncryption: sse}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.RemoveObject("my-bucket", "encrypted.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	testDestroy(t, resourceS3Object(), object, meta)
+	testDestroy(t, resourceS3Bucket(), bucket, meta)
+
+	out := logs.String()
+	for _, want := range []string{"[DEBUG]", "HTTP Trace", "[s3_object.create my-bucket/object.txt]", "X-Amz-Server-Side-Encryption-Customer-Key", redacted} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected the log to contain %q, got:\n%s", want, out)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(signatures) < 1 {
+		t.Fatal("expected signed requests")
+	}
+	secrets := map[string]string{
+		"secret key":    "fake/s3/secret/key",
+		"session token": "fake-s3-session-token",
+		"SSE-C key":     base64.StdEncoding.EncodeToString(key),
+	}
+	for name, secret := range secrets {
+		if strings.Contains(out, secret) {
+			t.Errorf("expected the %s to be redacted from the log", name)
+		}
+	}
+	for _, signature := range signatures {
+		if strings.Contains(out, signature) {
+			t.Errorf("expected the signature %s to be redacted from the log", signature)
+		}
+	}
+}
+
+func TestRedactPatterns(t *testing.T) {
+	cases := []struct {
+		in, out string
+	}{
+		{
+			"Authorization: AWS4-HMAC-SHA256 Credential=AKIA/20180101/us-east-1/s3/aws4_request, SignedHeaders=host, Signature=abcdef0123",
+			"Authorization: AWS4-HMAC-SHA256 Credential=AKIA/20180101/us-east-1/s3/aws4_request, SignedHeaders=host, Signature=" + redacted,
+		},
+		{
+			"Authorization: AWS AKIA:c2lnbmF0dXJl",
+			"Authorization: AWS AKIA:" + redacted,
+		},
+		{
+			"GET /my-bucket/object.txt?X-Amz-Signature=abcdef0123&X-Amz-Security-Token=token HTTP/1.1",
+			"GET /my-bucket/object.txt?X-Amz-Signature=" + redacted + "&X-Amz-Security-Token=" + redacted + " HTTP/1.1",
+		},
+		{
+			"X-Amz-Security-Token: token",
+			"X-Amz-Security-Token: " + redacted,
+		},
+		{
+			"X-Amz-Copy-Source-Server-Side-Encryption-Customer-Key: a2V5",
+			"X-Amz-Copy-Source-Server-Side-Encryption-Customer-Key: " + redacted,
+		},
+		{
+			"X-Amz-Mfa: arn:aws:iam::123456789012:mfa/root 123456\r\nX-Amz-Date: 20180101T000000Z",
+			"X-Amz-Mfa: arn:aws:iam::123456789012:mfa/root " + redacted + "\r\nX-Amz-Date: 20180101T000000Z",
+		},
+		{
+			"[DEBUG] Bucket: [my-bucket]",
+			"[DEBUG] Bucket: [my-bucket]",
+		},
+	}
+	for _, c := range cases {
+		if out := redact(c.in); out != c.out {
+			t.Errorf("redact(%q) = %q, want %q", c.in, out, c.out)
+		}
+	}
+}
+
+func TestTraceFile(t *testing.T) {
+	s := newFakeS3(t)
+	path := filepath.Join(t.TempDir(), "trace.log")
+	meta := s.meta(t, map[string]interface{}{
+		"trace_http": true,
+		"trace_file": path,
+	})
+	testApply(t, resourceS3Bucket(), nil, map[string]interface{}{"bucket": "my-bucket"}, meta)
+
+	trace, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{traceStart + " [s3_bucket.create my-bucket]", "PUT /my-bucket/", traceEnd} {
+		if !strings.Contains(string(trace), want) {
+			t.Fatalf("expected the trace file to contain %q, got:\n%s", want, trace)
+		}
+	}
+	if strings.Contains(string(trace), "fake/s3/secret/key") {
+		t.Fatal("expected the secret key to be redacted from the trace file")
+	}
+}
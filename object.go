@@ -1,9 +1,20 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"strings"
+
 	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/minio/minio-go"
 )
 
+// Objects larger than maxInlineContentSize are never downloaded into the
+// state.  Drift on them is shown with a placeholder content instead.
+const maxInlineContentSize = 1 << 20
 
 func resourceS3Object() *schema.Resource {
 	return &schema.Resource{
@@ -12,39 +23,217 @@ func resourceS3Object() *schema.Resource {
 		Update: resourceS3ObjectUpdate,
 		Delete: resourceS3ObjectDelete,
 
+		Timeouts: defaultResourceTimeouts,
+
+		Importer: &schema.ResourceImporter{
+			State: resourceS3ObjectImport,
+		},
+
 		Schema: map[string]*schema.Schema{
 			"bucket": {
 				Type:     schema.TypeString,
 				Required: true,
+				ForceNew: true,
 			},
 			"name": {
 				Type:     schema.TypeString,
 				Required: true,
+				ForceNew: true,
 			},
 			"content": {
-				Type: schema.TypeString,
+				Type:     schema.TypeString,
 				Required: true,
 			},
 			"content_type": {
-				Type: schema.TypeString,
+				Type:     schema.TypeString,
 				Required: true,
 			},
+			"etag": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"size": {
+				Type:     schema.TypeInt,
+				Computed: true,
+			},
+			"debug": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
 		},
 	}
 }
 
 func resourceS3ObjectCreate(d *schema.ResourceData, meta interface{}) error {
-	return nil
+	return resourceS3ObjectPut(d, meta, schema.TimeoutCreate)
+}
+
+// resourceS3ObjectPut uploads the configured content.  Create and Update only
+// differ in the timeout that applies.
+func resourceS3ObjectPut(d *schema.ResourceData, meta interface{}, timeout string) error {
+	debug := d.Get("debug").(bool)
+	bucket := d.Get("bucket").(string)
+	name := d.Get("name").(string)
+	content := d.Get("content").(string)
+	content_type := d.Get("content_type").(string)
+	s3_client := meta.(*s3Client).clientFor("s3_object."+timeout, bucket+"/"+name)
+	ctx, cancel := meta.(*s3Client).operationContext(d, timeout)
+	defer cancel()
+
+	if debug {
+		log.Printf("[DEBUG] Creating object [%s] in bucket [%s]", name, bucket)
+	}
+
+	err := meta.(*s3Client).retry(ctx, "s3_object."+timeout, true, func() error {
+		_, err := s3_client.PutObjectWithContext(ctx, bucket, name, strings.NewReader(content), int64(len(content)),
+			minio.PutObjectOptions{ContentType: content_type})
+		return err
+	})
+	if err != nil {
+		abortUpload(ctx, s3_client, bucket, name)
+		log.Printf("[FATAL] Unable to create object [%s] in bucket [%s].  Error: %v", name, bucket, err)
+		return errors.New(fmt.Sprintf("Unable to create object [%s] in bucket [%s].  Error: %v", name, bucket, err))
+	}
+
+	if debug {
+		log.Printf("[DEBUG] Created object [%s] in bucket [%s]", name, bucket)
+	}
+
+	// The new ETag is only known after the next read, which must not mistake
+	// our own upload for drift.
+	d.Set("etag", "")
+	d.SetId(fmt.Sprintf("%s/%s", bucket, name))
+	return resourceS3ObjectRead(d, meta)
 }
 
 func resourceS3ObjectRead(d *schema.ResourceData, meta interface{}) error {
+	debug := d.Get("debug").(bool)
+	bucket := d.Get("bucket").(string)
+	name := d.Get("name").(string)
+	content := d.Get("content").(string)
+	s3_client := meta.(*s3Client).clientFor("s3_object.read", bucket+"/"+name)
+	ctx, cancel := meta.(*s3Client).operationContext(d, schema.TimeoutRead)
+	defer cancel()
+
+	if debug {
+		log.Printf("[DEBUG] Reading object [%s] from bucket [%s]", name, bucket)
+	}
+
+	var info minio.ObjectInfo
+	err := meta.(*s3Client).retry(ctx, "s3_object.read", true, func() (err error) {
+		info, err = s3_client.StatObject(bucket, name, minio.StatObjectOptions{})
+		return err
+	})
+	if err != nil {
+		if isGone(err) {
+			log.Printf("[WARN] Object [%s] not found in bucket [%s], removing from state", name, bucket)
+			d.SetId("")
+			return nil
+		}
+		log.Printf("[FATAL] Unable to read object [%s] from bucket [%s].  Error: %v", name, bucket, err)
+		return errors.New(fmt.Sprintf("Unable to read object [%s] from bucket [%s].  Error: %v", name, bucket, err))
+	}
+
+	// Drift is detected by comparing the object with the ETag and size stored
+	// on the last read.  They are empty right after an upload or an import.
+	stored_etag := d.Get("etag").(string)
+	changed := len(stored_etag) > 0 && (info.ETag != stored_etag || info.Size != int64(d.Get("size").(int)))
+	imported := len(stored_etag) < 1 && len(content) < 1 && info.Size > 0
+	if changed && debug {
+		log.Printf("[DEBUG] Object [%s] in bucket [%s] changed outside of Terraform.  ETag: [%s], Size: [%d]",
+			name, bucket, info.ETag, info.Size)
+	}
+	if (changed || imported) && info.Size > maxInlineContentSize {
+		// Too large to be inline content.  Record a placeholder so the plan
+		// shows the drift and Update puts our content back.
+		d.Set("content", fmt.Sprintf("<object changed outside of Terraform: ETag %s, %d bytes>", info.ETag, info.Size))
+	} else if changed || imported {
+		var remote []byte
+		err := meta.(*s3Client).retry(ctx, "s3_object.read", true, func() error {
+			object, err := s3_client.GetObjectWithContext(ctx, bucket, name, minio.GetObjectOptions{})
+			if err != nil {
+				return err
+			}
+			defer object.Close()
+			remote, err = ioutil.ReadAll(io.LimitReader(object, maxInlineContentSize+1))
+			return err
+		})
+		if err != nil {
+			return errors.New(fmt.Sprintf("Unable to read object [%s] from bucket [%s].  Error: %v", name, bucket, err))
+		}
+		d.Set("content", string(remote))
+	}
+
+	d.Set("etag", info.ETag)
+	d.Set("size", info.Size)
+	d.Set("content_type", info.ContentType)
+
+	if debug {
+		log.Printf("[DEBUG] Read object [%s] from bucket [%s]", name, bucket)
+	}
 	return nil
 }
 
 func resourceS3ObjectUpdate(d *schema.ResourceData, meta interface{}) error {
-	return nil
+	return resourceS3ObjectPut(d, meta, schema.TimeoutUpdate)
 }
 
 func resourceS3ObjectDelete(d *schema.ResourceData, meta interface{}) error {
+	debug := d.Get("debug").(bool)
+	bucket := d.Get("bucket").(string)
+	name := d.Get("name").(string)
+	s3_client := meta.(*s3Client).clientFor("s3_object.delete", bucket+"/"+name)
+	ctx, cancel := meta.(*s3Client).operationContext(d, schema.TimeoutDelete)
+	defer cancel()
+
+	if debug {
+		log.Printf("[DEBUG] Deleting object [%s] from bucket [%s]", name, bucket)
+	}
+
+	err := meta.(*s3Client).retry(ctx, "s3_object.delete", true, func() error {
+		return removeObject(ctx, s3_client, bucket, name)
+	})
+	if err != nil && !isGone(err) {
+		log.Printf("[FATAL] Unable to delete object [%s] from bucket [%s].  Error: %v", name, bucket, err)
+		return errors.New(fmt.Sprintf("Unable to delete object [%s] from bucket [%s].  Error: %v", name, bucket, err))
+	}
+
+	if debug {
+		log.Printf("[DEBUG] Deleted object [%s] from bucket [%s]", name, bucket)
+	}
 	return nil
-}
\ No newline at end of file
+}
+
+func resourceS3ObjectImport(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	bucket, name, err := parseS3ObjectId(d.Id())
+	if err != nil {
+		return nil, err
+	}
+	s3_client := meta.(*s3Client).clientFor("s3_object.import", bucket+"/"+name)
+	ctx, cancel := meta.(*s3Client).operationContext(d, schema.TimeoutRead)
+	defer cancel()
+
+	err = meta.(*s3Client).retry(ctx, "s3_object.import", true, func() error {
+		_, err := s3_client.StatObject(bucket, name, minio.StatObjectOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Unable to import object [%s] from bucket [%s].  Error: %v", name, bucket, err))
+	}
+
+	d.Set("bucket", bucket)
+	d.Set("name", name)
+	d.Set("debug", false)
+	return []*schema.ResourceData{d}, nil
+}
+
+// parseS3ObjectId splits a resource ID of the form bucket/key.  The key may
+// itself contain slashes.
+func parseS3ObjectId(id string) (string, string, error) {
+	parts := strings.SplitN(id, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", errors.New(fmt.Sprintf("Invalid ID [%s].  Expected format: bucket/key", id))
+	}
+	return parts[0], parts[1], nil
+}
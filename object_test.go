@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestResourceS3Object(t *testing.T) {
+	s := newFakeS3(t)
+	s.putBucket("my-bucket", "")
+	meta := s.meta(t, nil)
+	r := resourceS3Object()
+
+	// Create
+	raw := map[string]interface{}{
+		"bucket":       "my-bucket",
+		"name":         "config/app.json",
+		"content":      `{"key": "value"}`,
+		"content_type": "application/json",
+	}
+	state := testApply(t, r, nil, raw, meta)
+	testCheckAttributes(t, state, map[string]string{
+		"id":      "my-bucket/config/app.json",
+		"content": `{"key": "value"}`,
+		"etag":    s.object("my-bucket", "config/app.json").etag,
+		"size":    "16",
+	})
+	testCheckNoPlan(t, r, state, raw, meta)
+
+	// Update
+	raw["content"] = `{"key": "other value"}`
+	raw["content_type"] = "text/plain"
+	state = testApply(t, r, state, raw, meta)
+	object := s.object("my-bucket", "config/app.json")
+	if string(object.data) != `{"key": "other value"}` || object.content_type != "text/plain" {
+		t.Fatalf("expected the object to be uploaded again, got: %q (%s)", object.data, object.content_type)
+	}
+	testCheckNoPlan(t, r, state, raw, meta)
+
+	// Import
+	imported := testImport(t, r, "my-bucket/config/app.json", meta)
+	testCheckAttributes(t, imported, map[string]string{
+		"bucket":       "my-bucket",
+		"name":         "config/app.json",
+		"content":      `{"key": "other value"}`,
+		"content_type": "text/plain",
+	})
+
+	// Destroy
+	testDestroy(t, r, state, meta)
+	if s.object("my-bucket", "config/app.json") != nil {
+		t.Fatal("expected the object to be removed")
+	}
+	if state := testRefresh(t, r, state, meta); state != nil {
+		t.Fatalf("expected the removed object to leave the state, got: %v", state)
+	}
+}
+
+func TestResourceS3ObjectDrift(t *testing.T) {
+	s := newFakeS3(t)
+	s.putBucket("my-bucket", "")
+	meta := s.meta(t, nil)
+	r := resourceS3Object()
+	raw := map[string]interface{}{
+		"bucket":       "my-bucket",
+		"name":         "object.txt",
+		"content":      "content",
+		"content_type": "text/plain",
+	}
+	state := testApply(t, r, nil, raw, meta)
+
+	// Small objects are downloaded to show what changed.
+	s.putObject("my-bucket", "object.txt", "text/plain", []byte("changed"))
+	diff := testPlan(t, r, state, raw, meta)
+	if diff == nil || diff.Attributes["content"] == nil || diff.Attributes["content"].Old != "changed" {
+		t.Fatalf("expected a content change from the remote content, got: %v", diff)
+	}
+
+	// Large objects are only reported.
+	s.putObject("my-bucket", "object.txt", "text/plain", bytes.Repeat([]byte("x"), maxInlineContentSize+1))
+	gets := s.count("GET /my-bucket/object.txt")
+	diff = testPlan(t, r, state, raw, meta)
+	if diff == nil || diff.Attributes["content"] == nil || !strings.Contains(diff.Attributes["content"].Old, "changed outside of Terraform") {
+		t.Fatalf("expected a content change from the placeholder, got: %v", diff)
+	}
+	if n := s.count("GET /my-bucket/object.txt") - gets; n != 0 {
+		t.Fatalf("expected the large object not to be downloaded, got %d requests", n)
+	}
+
+	// Apply puts the configured content back.
+	state = testApply(t, r, testRefresh(t, r, state, meta), raw, meta)
+	if object := s.object("my-bucket", "object.txt"); string(object.data) != "content" {
+		t.Fatalf("expected the object to be restored, got %d bytes", len(object.data))
+	}
+	testCheckNoPlan(t, r, state, raw, meta)
+}
+
+func TestResourceS3ObjectErrors(t *testing.T) {
+	s := newFakeS3(t)
+	s.putBucket("my-bucket", "")
+	meta := s.meta(t, nil)
+	r := resourceS3Object()
+	raw := map[string]interface{}{
+		"bucket":       "my-bucket",
+		"name":         "object.txt",
+		"content":      "content",
+		"content_type": "text/plain",
+	}
+
+	// Uploads that are denied fail the create.
+	s.inject(fakeFault{method: "PUT", key: "object.txt", status: http.StatusForbidden, code: "AccessDenied", times: 1})
+	diff, err := r.Diff(nil, testResourceConfig(t, raw), meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Apply(nil, diff, meta); err == nil || !strings.Contains(err.Error(), "AccessDenied") && !strings.Contains(err.Error(), "Access Denied") {
+		t.Fatalf("expected Access Denied error, got: %v", err)
+	}
+
+	// Unavailable servers are retried.
+	s.inject(fakeFault{method: "PUT", key: "object.txt", status: http.StatusServiceUnavailable, code: "SlowDown", times: 2})
+	state := testApply(t, r, nil, raw, meta)
+	testCheckAttributes(t, state, map[string]string{"id": "my-bucket/object.txt"})
+
+	// Objects removed outside of Terraform leave the state.
+	s.inject(fakeFault{method: "HEAD", key: "object.txt", status: http.StatusNotFound, code: "NoSuchKey", times: 1})
+	if state := testRefresh(t, r, state, meta); state != nil {
+		t.Fatalf("expected the missing object to leave the state, got: %v", state)
+	}
+}
@@ -1,18 +1,22 @@
 package main
 
 import (
+	"fmt"
 	"log"
-	"github.com/hashicorp/terraform/terraform"
+	"time"
+
 	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/terraform"
 )
 
 func Provider() terraform.ResourceProvider {
-	return &schema.Provider{
+	provider := &schema.Provider{
 		Schema: map[string]*schema.Schema{
 			"s3_server": {
-				Type:        schema.TypeString,
-				Required:    true,
-				Description: "S3 Server",
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validateS3Server,
+				Description:  "S3 Server host[:port] or URL (e.g. https://s3.example.com:9000)",
 			},
 			"s3_region": {
 				Type:        schema.TypeString,
@@ -22,25 +26,183 @@ func Provider() terraform.ResourceProvider {
 			},
 			"s3_access_key": {
 				Type:        schema.TypeString,
-				Required:    true,
+				Optional:    true,
 				Description: "S3 Server Access Key",
 			},
 			"s3_secret_key": {
 				Type:        schema.TypeString,
-				Required:    true,
+				Optional:    true,
 				Description: "S3 Server Secret Key",
 			},
+			"session_token": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Session token for temporary credentials",
+			},
+			"assume_role": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Description: "Exchange the base credentials for temporary ones through an STS AssumeRole endpoint",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"role_arn": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "ARN of the role to assume",
+						},
+						"session_name": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     "terraform",
+							Description: "Session name to use when assuming the role (default: terraform)",
+						},
+						"duration": {
+							Type:        schema.TypeInt,
+							Optional:    true,
+							Default:     3600,
+							Description: "Duration of the temporary credentials in seconds (default: 3600)",
+						},
+						"external_id": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "External ID to pass when assuming the role",
+						},
+						"sts_endpoint": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "STS endpoint URL (default: the S3 Server)",
+						},
+					},
+				},
+			},
+			"profile": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Profile to use from the shared AWS credentials file (default: AWS_PROFILE or default)",
+			},
+			"mc_alias": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Alias to use from the Minio client configuration file (default: MINIO_ALIAS or s3)",
+			},
 			"s3_api_signature": {
 				Type:        schema.TypeString,
 				Optional:    true,
 				Default:     "v4",
 				Description: "S3 Server API Signature (type: string, options: v2 or v4, default: v4)",
 			},
+			"bucket_lookup": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "auto",
+				ValidateFunc: validateBucketLookup,
+				Description:  "Bucket addressing style (type: string, options: auto, dns or path, default: auto)",
+			},
 			"s3_ssl": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Description: "Use SSL to connect to the S3 Server? (default: derived from the s3_server scheme, or false)",
+			},
+			"ca_cert_file": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Path to a PEM encoded CA bundle used to verify the S3 Server certificate",
+			},
+			"ca_cert_pem": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "PEM encoded CA bundle used to verify the S3 Server certificate",
+			},
+			"client_cert": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Path to a PEM encoded client certificate for mutual TLS",
+			},
+			"client_key": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Path to the PEM encoded private key of client_cert",
+			},
+			"tls_server_name": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Server name used to verify the S3 Server certificate",
+			},
+			"insecure_skip_verify": {
 				Type:        schema.TypeBool,
 				Optional:    true,
 				Default:     false,
-				Description: "Use SSL to connect to the S3 Server? (default: false)",
+				Description: "Skip verification of the S3 Server certificate (default: false)",
+			},
+			"http_proxy": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "URL of the HTTP proxy used to reach the S3 Server (default: HTTP_PROXY/HTTPS_PROXY)",
+			},
+			"no_proxy": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Comma separated list of hosts and domains that bypass http_proxy",
+			},
+			"connect_timeout": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "30s",
+				ValidateFunc: validateDuration,
+				Description:  "Timeout for establishing connections to the S3 Server (default: 30s)",
+			},
+			"request_timeout": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "0s",
+				ValidateFunc: validateDuration,
+				Description:  "Timeout waiting for the S3 Server to answer a request, 0s disables it (default: 0s)",
+			},
+			"idle_conn_timeout": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "90s",
+				ValidateFunc: validateDuration,
+				Description:  "Time an idle connection is kept in the pool (default: 90s)",
+			},
+			"max_idle_conns_per_host": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Default:     100,
+				Description: "Maximum number of idle connections kept per host (default: 100)",
+			},
+			"max_retries": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      5,
+				ValidateFunc: validateNonNegative,
+				Description:  "Maximum number of retries for throttled or failed requests (default: 5)",
+			},
+			"retry_min_backoff": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "1s",
+				ValidateFunc: validateDuration,
+				Description:  "Minimum time to wait before retrying a request (default: 1s)",
+			},
+			"retry_max_backoff": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "30s",
+				ValidateFunc: validateDuration,
+				Description:  "Maximum time to wait before retrying a request (default: 30s)",
+			},
+			"trace_http": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Trace every HTTP request sent to the S3 Server (default: false)",
+			},
+			"trace_file": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "File the HTTP trace is written to, rotated every 10MB (default: the provider log)",
 			},
 			"s3_debug": {
 				Type:        schema.TypeBool,
@@ -51,16 +213,38 @@ func Provider() terraform.ResourceProvider {
 		},
 
 		ResourcesMap: map[string]*schema.Resource{
-			"s3_bucket": resourceS3Bucket(),
-			"s3_object": resourceS3Object(),
-			"s3_file":   resourceS3File(),
+			"s3_bucket":              resourceS3Bucket(),
+			"s3_bucket_cors":         resourceS3BucketCors(),
+			"s3_bucket_lifecycle":    resourceS3BucketLifecycle(),
+			"s3_bucket_notification": resourceS3BucketNotification(),
+			"s3_bucket_policy":       resourceS3BucketPolicy(),
+			"s3_bucket_website":      resourceS3BucketWebsite(),
+			"s3_object":              resourceS3Object(),
+			"s3_file":                resourceS3File(),
 		},
 
-		ConfigureFunc: providerConfigure,
+		DataSourcesMap: map[string]*schema.Resource{
+			"s3_bucket_policy_document": dataSourceS3BucketPolicyDocument(),
+		},
+	}
+
+	provider.ConfigureFunc = func(d *schema.ResourceData) (interface{}, error) {
+		client, err := providerConfigure(d)
+		if err != nil {
+			return nil, err
+		}
+		// Cancel in-flight operations when Terraform is interrupted.
+		client.(*s3Client).stopCtx = provider.StopContext()
+		return client, nil
 	}
+	return provider
 }
 
 func providerConfigure(d *schema.ResourceData) (interface{}, error) {
+	redactLogs()
+	registerSecret(d.Get("s3_secret_key").(string))
+	registerSecret(d.Get("session_token").(string))
+
 	debug := d.Get("s3_debug").(bool)
 	if debug {
 		log.Printf("[DEBUG] Initializing the S3 Provider")
@@ -70,9 +254,81 @@ func providerConfigure(d *schema.ResourceData) (interface{}, error) {
 		s3_region:     d.Get("s3_region").(string),
 		s3_access_key: d.Get("s3_access_key").(string),
 		s3_secret_key: d.Get("s3_secret_key").(string),
+		session_token: d.Get("session_token").(string),
 		api_signature: d.Get("s3_api_signature").(string),
+		bucket_lookup: d.Get("bucket_lookup").(string),
+		profile:       d.Get("profile").(string),
+		alias:         d.Get("mc_alias").(string),
 		ssl:           d.Get("s3_ssl").(bool),
 		debug:         d.Get("s3_debug").(bool),
+
+		ca_cert_file:         d.Get("ca_cert_file").(string),
+		ca_cert_pem:          d.Get("ca_cert_pem").(string),
+		client_cert:          d.Get("client_cert").(string),
+		client_key:           d.Get("client_key").(string),
+		tls_server_name:      d.Get("tls_server_name").(string),
+		insecure_skip_verify: d.Get("insecure_skip_verify").(bool),
+
+		http_proxy:              d.Get("http_proxy").(string),
+		no_proxy:                d.Get("no_proxy").(string),
+		max_idle_conns_per_host: d.Get("max_idle_conns_per_host").(int),
+
+		trace_http: d.Get("trace_http").(bool),
+		trace_file: d.Get("trace_file").(string),
+
+		max_retries: d.Get("max_retries").(int),
+	}
+	if _, ok := d.GetOkExists("s3_ssl"); ok {
+		config.ssl_set = true
+	}
+	// Durations have already been checked by validateDuration.
+	config.connect_timeout, _ = time.ParseDuration(d.Get("connect_timeout").(string))
+	config.request_timeout, _ = time.ParseDuration(d.Get("request_timeout").(string))
+	config.idle_conn_timeout, _ = time.ParseDuration(d.Get("idle_conn_timeout").(string))
+	config.retry_min_backoff, _ = time.ParseDuration(d.Get("retry_min_backoff").(string))
+	config.retry_max_backoff, _ = time.ParseDuration(d.Get("retry_max_backoff").(string))
+	if v, ok := d.GetOk("assume_role"); ok {
+		assume_role := v.([]interface{})[0].(map[string]interface{})
+		config.assume_role = &assumeRoleConfig{
+			role_arn:     assume_role["role_arn"].(string),
+			session_name: assume_role["session_name"].(string),
+			duration:     assume_role["duration"].(int),
+			external_id:  assume_role["external_id"].(string),
+			sts_endpoint: assume_role["sts_endpoint"].(string),
+		}
 	}
 	return config.NewClient()
 }
+
+func validateDuration(v interface{}, k string) (ws []string, errors []error) {
+	duration, err := time.ParseDuration(v.(string))
+	if err != nil {
+		errors = append(errors, fmt.Errorf("%q must be a duration such as 30s or 5m: %v", k, err))
+	} else if duration < 0 {
+		errors = append(errors, fmt.Errorf("%q must not be negative", k))
+	}
+	return
+}
+
+func validateS3Server(v interface{}, k string) (ws []string, errors []error) {
+	if _, _, err := parseS3Server(v.(string)); err != nil {
+		errors = append(errors, fmt.Errorf("%q: %v", k, err))
+	}
+	return
+}
+
+func validateBucketLookup(v interface{}, k string) (ws []string, errors []error) {
+	switch v.(string) {
+	case "auto", "dns", "path":
+	default:
+		errors = append(errors, fmt.Errorf("%q must be one of auto, dns or path, got: %s", k, v.(string)))
+	}
+	return
+}
+
+func validateNonNegative(v interface{}, k string) (ws []string, errors []error) {
+	if v.(int) < 0 {
+		errors = append(errors, fmt.Errorf("%q must not be negative, got: %d", k, v.(int)))
+	}
+	return
+}
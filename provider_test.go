@@ -0,0 +1,761 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestProvider(t *testing.T) {
+	if err := Provider().(*schema.Provider).InternalValidate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Bucket subresources the fake S3 server understands.  Requests for a bucket
+// without one of them create, check, list or delete the bucket itself.
+var fakeS3Subresources = []string{
+	"location", "policy", "notification", "versioning", "lifecycle",
+	"cors", "website", "delete", "uploads", "versions",
+}
+
+// Subresource configurations that are reported as missing until they are set,
+// together with the error code S3 uses for them.
+var fakeS3MissingCodes = map[string]string{
+	"policy":    "NoSuchBucketPolicy",
+	"lifecycle": "NoSuchLifecycleConfiguration",
+	"cors":      "NoSuchCORSConfiguration",
+	"website":   "NoSuchWebsiteConfiguration",
+}
+
+// fakeS3 is an in-process stand-in for an S3 server.  It keeps buckets,
+// objects and bucket subresources in memory and answers the path-style
+// requests sent by minio-go and bucketRequest.  Faults can be injected to
+// simulate denied, missing, failing or slow requests.
+type fakeS3 struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	buckets  map[string]*fakeBucket
+	faults   []*fakeFault
+	requests []string
+}
+
+type fakeBucket struct {
+	region       string
+	objects      map[string]*fakeObject
+	subresources map[string][]byte
+}
+
+type fakeObject struct {
+	data         []byte
+	content_type string
+	etag         string
+	modified     time.Time
+}
+
+// fakeFault matches requests by method, bucket, key and subresource.  Empty
+// fields match anything except subresource, which must match exactly so
+// faults are not used up by the location requests minio sends first.  A
+// matching request waits for delay and then fails with status and code,
+// unless status is 0.  times limits how many requests fail, 0 means all.
+type fakeFault struct {
+	method      string
+	bucket      string
+	key         string
+	subresource string
+	status      int
+	code        string
+	delay       time.Duration
+	times       int
+}
+
+type fakeS3Error struct {
+	XMLName    xml.Name `xml:"Error"`
+	Code       string   `xml:"Code"`
+	Message    string   `xml:"Message"`
+	BucketName string   `xml:"BucketName,omitempty"`
+	Key        string   `xml:"Key,omitempty"`
+	RequestId  string   `xml:"RequestId"`
+}
+
+type fakeListResult struct {
+	XMLName     xml.Name          `xml:"ListBucketResult"`
+	Name        string            `xml:"Name"`
+	Prefix      string            `xml:"Prefix"`
+	KeyCount    int               `xml:"KeyCount"`
+	MaxKeys     int               `xml:"MaxKeys"`
+	IsTruncated bool              `xml:"IsTruncated"`
+	Contents    []fakeListContent `xml:"Contents"`
+}
+
+type fakeListContent struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int    `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type fakeDeleteResult struct {
+	XMLName xml.Name        `xml:"DeleteResult"`
+	Deleted []objectVersion `xml:"Deleted"`
+}
+
+// newFakeS3 starts a fake S3 server that is shut down with the test.
+func newFakeS3(t *testing.T) *fakeS3 {
+	s := &fakeS3{buckets: map[string]*fakeBucket{}}
+	s.Server = httptest.NewServer(s)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// meta configures the provider against the fake server, with short retry
+// backoffs so retried requests do not slow the tests down.  extra overrides
+// or adds provider arguments.
+func (s *fakeS3) meta(t *testing.T, extra map[string]interface{}) interface{} {
+	raw := map[string]interface{}{
+		"s3_server":         s.URL,
+		"s3_access_key":     "AKIAFAKES3ACCESSKEY",
+		"s3_secret_key":     "fake/s3/secret/key",
+		"max_retries":       2,
+		"retry_min_backoff": "1ms",
+		"retry_max_backoff": "5ms",
+	}
+	for k, v := range extra {
+		raw[k] = v
+	}
+	p := Provider().(*schema.Provider)
+	if err := p.Configure(testResourceConfig(t, raw)); err != nil {
+		t.Fatal(err)
+	}
+	return p.Meta()
+}
+
+// inject adds a fault for the following requests.
+func (s *fakeS3) inject(f fakeFault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &f)
+}
+
+// putBucket creates a bucket directly, e.g. to set up drift or an import.
+func (s *fakeS3) putBucket(bucket, region string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buckets[bucket] = &fakeBucket{
+		region:       region,
+		objects:      map[string]*fakeObject{},
+		subresources: map[string][]byte{},
+	}
+}
+
+// putObject writes an object directly, bypassing the provider.
+func (s *fakeS3) putObject(bucket, key, content_type string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buckets[bucket].objects[key] = newFakeObject(content_type, data)
+}
+
+// object returns a copy of an object, or nil if it does not exist.
+func (s *fakeS3) object(bucket, key string) *fakeObject {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if b, ok := s.buckets[bucket]; ok {
+		if o, ok := b.objects[key]; ok {
+			c := *o
+			return &c
+		}
+	}
+	return nil
+}
+
+// subresource returns the stored configuration of a bucket subresource.
+func (s *fakeS3) subresource(bucket, name string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if b, ok := s.buckets[bucket]; ok {
+		return b.subresources[name]
+	}
+	return nil
+}
+
+// setSubresource stores the configuration of a bucket subresource directly.
+func (s *fakeS3) setSubresource(bucket, name string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buckets[bucket].subresources[name] = data
+}
+
+func (s *fakeS3) hasBucket(bucket string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.buckets[bucket]
+	return ok
+}
+
+// count returns how many requests of the form "METHOD /bucket[/key][?sub]"
+// the server received.
+func (s *fakeS3) count(request string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, r := range s.requests {
+		if r == request {
+			n++
+		}
+	}
+	return n
+}
+
+func newFakeObject(content_type string, data []byte) *fakeObject {
+	sum := md5.Sum(data)
+	if len(content_type) < 1 {
+		content_type = "application/octet-stream"
+	}
+	return &fakeObject{
+		data:         data,
+		content_type: content_type,
+		etag:         hex.EncodeToString(sum[:]),
+		modified:     time.Now().UTC().Truncate(time.Second),
+	}
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	parts := strings.SplitN(path, "/", 2)
+	bucket, key := parts[0], ""
+	if len(parts) > 1 {
+		key = parts[1]
+	}
+	query := r.URL.Query()
+	sub := ""
+	for _, name := range fakeS3Subresources {
+		if _, ok := query[name]; ok {
+			sub = name
+			break
+		}
+	}
+
+	request := fmt.Sprintf("%s /%s", r.Method, bucket)
+	if len(key) > 0 {
+		request += "/" + key
+	}
+	if len(sub) > 0 {
+		request += "?" + sub
+	}
+	s.mu.Lock()
+	s.requests = append(s.requests, request)
+	fault := s.fault(r.Method, bucket, key, sub)
+	s.mu.Unlock()
+
+	// Reading the body first lets the server notice when the client gives up
+	// on a delayed request.
+	body, _ := ioutil.ReadAll(r.Body)
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	if fault != nil {
+		if fault.delay > 0 {
+			select {
+			case <-time.After(fault.delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if fault.status > 0 {
+			fakeS3WriteError(w, r, fault.status, fault.code, bucket, key)
+			return
+		}
+	}
+
+	if len(r.Header.Get("Authorization")) < 1 {
+		fakeS3WriteError(w, r, http.StatusForbidden, "AccessDenied", bucket, key)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Requests signed for another region than the bucket's are redirected
+	// the way S3 does it.  minio signs location requests for us-east-1.
+	if b, ok := s.buckets[bucket]; ok && sub != "location" {
+		region := fakeS3SigningRegion(r)
+		if len(region) > 0 && region != b.signingRegion() {
+			w.Header().Set("X-Amz-Bucket-Region", b.signingRegion())
+			fakeS3WriteError(w, r, http.StatusBadRequest, "AuthorizationHeaderMalformed", bucket, key)
+			return
+		}
+	}
+
+	switch {
+	case len(bucket) < 1:
+		fakeS3WriteError(w, r, http.StatusNotImplemented, "NotImplemented", "", "")
+	case len(key) > 0:
+		s.serveObject(w, r, bucket, key)
+	case r.Method == "PUT" && sub == "":
+		s.createBucket(w, r, bucket)
+	default:
+		s.serveBucket(w, r, bucket, sub)
+	}
+}
+
+// fault returns the first fault matching the request and uses it up.  It is
+// called with s.mu held.
+func (s *fakeS3) fault(method, bucket, key, sub string) *fakeFault {
+	for i, f := range s.faults {
+		if (f.method != "" && f.method != method) || (f.bucket != "" && f.bucket != bucket) ||
+			(f.key != "" && f.key != key) || f.subresource != sub {
+			continue
+		}
+		if f.times > 0 {
+			if f.times--; f.times == 0 {
+				s.faults = append(s.faults[:i], s.faults[i+1:]...)
+			}
+		}
+		return f
+	}
+	return nil
+}
+
+func (s *fakeS3) createBucket(w http.ResponseWriter, r *http.Request, bucket string) {
+	if _, ok := s.buckets[bucket]; ok {
+		fakeS3WriteError(w, r, http.StatusConflict, "BucketAlreadyOwnedByYou", bucket, "")
+		return
+	}
+	var config struct {
+		Location string `xml:"LocationConstraint"`
+	}
+	if body, _ := ioutil.ReadAll(r.Body); len(body) > 0 {
+		if err := xml.Unmarshal(body, &config); err != nil {
+			fakeS3WriteError(w, r, http.StatusBadRequest, "MalformedXML", bucket, "")
+			return
+		}
+	}
+	s.buckets[bucket] = &fakeBucket{
+		region:       config.Location,
+		objects:      map[string]*fakeObject{},
+		subresources: map[string][]byte{},
+	}
+	w.Header().Set("Location", "/"+bucket)
+}
+
+func (s *fakeS3) serveBucket(w http.ResponseWriter, r *http.Request, bucket, sub string) {
+	b, ok := s.buckets[bucket]
+	if !ok {
+		fakeS3WriteError(w, r, http.StatusNotFound, "NoSuchBucket", bucket, "")
+		return
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+
+	switch r.Method + " " + sub {
+	case "HEAD ":
+	case "DELETE ":
+		if len(b.objects) > 0 {
+			fakeS3WriteError(w, r, http.StatusConflict, "BucketNotEmpty", bucket, "")
+			return
+		}
+		delete(s.buckets, bucket)
+		w.WriteHeader(http.StatusNoContent)
+	case "GET ":
+		result := fakeListResult{Name: bucket, Prefix: r.URL.Query().Get("prefix"), MaxKeys: 1000}
+		for _, name := range b.keys() {
+			if !strings.HasPrefix(name, result.Prefix) {
+				continue
+			}
+			o := b.objects[name]
+			result.Contents = append(result.Contents, fakeListContent{
+				Key:          name,
+				LastModified: o.modified.Format("2006-01-02T15:04:05.000Z"),
+				ETag:         `"` + o.etag + `"`,
+				Size:         len(o.data),
+				StorageClass: "STANDARD",
+			})
+		}
+		result.KeyCount = len(result.Contents)
+		fakeS3WriteXML(w, result)
+	case "GET location":
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><LocationConstraint xmlns="%s">%s</LocationConstraint>`,
+			s3XMLNamespace, b.region)
+	case "GET uploads":
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><ListMultipartUploadsResult xmlns="%s"><Bucket>%s</Bucket><IsTruncated>false</IsTruncated></ListMultipartUploadsResult>`,
+			s3XMLNamespace, bucket)
+	case "GET versions":
+		var result listVersionsResult
+		for _, name := range b.keys() {
+			result.Versions = append(result.Versions, objectVersion{Key: name, VersionId: "null"})
+		}
+		fakeS3WriteXML(w, struct {
+			XMLName xml.Name `xml:"ListVersionsResult"`
+			listVersionsResult
+		}{listVersionsResult: result})
+	case "POST delete":
+		var request deleteObjectsRequest
+		if err := xml.Unmarshal(body, &request); err != nil {
+			fakeS3WriteError(w, r, http.StatusBadRequest, "MalformedXML", bucket, "")
+			return
+		}
+		result := fakeDeleteResult{}
+		for _, object := range request.Objects {
+			delete(b.objects, object.Key)
+			result.Deleted = append(result.Deleted, object)
+		}
+		fakeS3WriteXML(w, result)
+	case "GET notification", "GET versioning":
+		if data, ok := b.subresources[sub]; ok {
+			w.Write(data)
+			return
+		}
+		name := "NotificationConfiguration"
+		if sub == "versioning" {
+			name = "VersioningConfiguration"
+		}
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><%s xmlns="%s"></%s>`, name, s3XMLNamespace, name)
+	case "GET policy", "GET lifecycle", "GET cors", "GET website":
+		data, ok := b.subresources[sub]
+		if !ok {
+			fakeS3WriteError(w, r, http.StatusNotFound, fakeS3MissingCodes[sub], bucket, "")
+			return
+		}
+		w.Write(data)
+	case "PUT policy", "PUT notification", "PUT versioning", "PUT lifecycle", "PUT cors", "PUT website":
+		// Like Amazon S3, changing MFA delete, or the versioning of a bucket
+		// with MFA delete enabled, takes the serial number and code of an MFA
+		// device.
+		if sub == "versioning" && len(strings.Fields(r.Header.Get("X-Amz-Mfa"))) != 2 &&
+			(bytes.Contains(body, []byte("<MfaDelete>")) || bytes.Contains(b.subresources[sub], []byte("<MfaDelete>Enabled"))) {
+			fakeS3WriteError(w, r, http.StatusForbidden, "AccessDenied", bucket, "")
+			return
+		}
+		b.subresources[sub] = body
+		if sub == "policy" {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	case "DELETE policy", "DELETE lifecycle", "DELETE cors", "DELETE website":
+		delete(b.subresources, sub)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		fakeS3WriteError(w, r, http.StatusNotImplemented, "NotImplemented", bucket, "")
+	}
+}
+
+func (s *fakeS3) serveObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	b, ok := s.buckets[bucket]
+	if !ok {
+		fakeS3WriteError(w, r, http.StatusNotFound, "NoSuchBucket", bucket, key)
+		return
+	}
+
+	switch r.Method {
+	case "PUT":
+		if source := r.Header.Get("X-Amz-Copy-Source"); len(source) > 0 {
+			source, _ = url.PathUnescape(strings.TrimPrefix(source, "/"))
+			parts := strings.SplitN(source, "/", 2)
+			src_bucket, ok := s.buckets[parts[0]]
+			if !ok || len(parts) < 2 || src_bucket.objects[parts[1]] == nil {
+				fakeS3WriteError(w, r, http.StatusNotFound, "NoSuchKey", parts[0], "")
+				return
+			}
+			o := *src_bucket.objects[parts[1]]
+			o.modified = time.Now().UTC().Truncate(time.Second)
+			b.objects[key] = &o
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><CopyObjectResult><ETag>"%s"</ETag><LastModified>%s</LastModified></CopyObjectResult>`,
+				o.etag, o.modified.Format("2006-01-02T15:04:05.000Z"))
+			return
+		}
+		data, err := ioutil.ReadAll(r.Body)
+		if r.Header.Get("X-Amz-Content-Sha256") == "STREAMING-AWS4-HMAC-SHA256-PAYLOAD" && err == nil {
+			data, err = fakeS3DecodeChunks(data)
+		}
+		if err != nil {
+			fakeS3WriteError(w, r, http.StatusBadRequest, "IncompleteBody", bucket, key)
+			return
+		}
+		o := newFakeObject(r.Header.Get("Content-Type"), data)
+		b.objects[key] = o
+		w.Header().Set("ETag", `"`+o.etag+`"`)
+	case "HEAD", "GET":
+		o, ok := b.objects[key]
+		if !ok {
+			fakeS3WriteError(w, r, http.StatusNotFound, "NoSuchKey", bucket, key)
+			return
+		}
+		w.Header().Set("ETag", `"`+o.etag+`"`)
+		w.Header().Set("Content-Type", o.content_type)
+		w.Header().Set("Last-Modified", o.modified.Format(http.TimeFormat))
+		http.ServeContent(w, r, "", o.modified, bytes.NewReader(o.data))
+	case "DELETE":
+		delete(b.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		fakeS3WriteError(w, r, http.StatusNotImplemented, "NotImplemented", bucket, key)
+	}
+}
+
+// signingRegion returns the region requests for the bucket must be signed for.
+func (b *fakeBucket) signingRegion() string {
+	switch b.region {
+	case "":
+		return "us-east-1"
+	case "EU":
+		return "eu-west-1"
+	}
+	return b.region
+}
+
+func (b *fakeBucket) keys() []string {
+	keys := make([]string, 0, len(b.objects))
+	for name := range b.objects {
+		keys = append(keys, name)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// fakeS3SigningRegion returns the region of a V4 signed request, taken from
+// the credential scope "<key>/<date>/<region>/s3/aws4_request".
+func fakeS3SigningRegion(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	i := strings.Index(auth, "Credential=")
+	if i < 0 {
+		return ""
+	}
+	scope := strings.Split(strings.SplitN(auth[i+len("Credential="):], ",", 2)[0], "/")
+	if len(scope) != 5 {
+		return ""
+	}
+	return scope[2]
+}
+
+// fakeS3DecodeChunks returns the payload of a body sent with streaming V4
+// signatures, which minio uses for uploads over plain HTTP.  Each chunk is
+// "<hex size>;chunk-signature=<signature>\r\n<data>\r\n".
+func fakeS3DecodeChunks(body []byte) ([]byte, error) {
+	var data []byte
+	for {
+		i := bytes.Index(body, []byte("\r\n"))
+		if i < 0 {
+			return nil, fmt.Errorf("missing chunk header")
+		}
+		var size int
+		if _, err := fmt.Sscanf(string(body[:i]), "%x;", &size); err != nil {
+			return nil, err
+		}
+		body = body[i+2:]
+		if size == 0 {
+			return data, nil
+		}
+		if len(body) < size+2 {
+			return nil, fmt.Errorf("short chunk")
+		}
+		data = append(data, body[:size]...)
+		body = body[size+2:]
+	}
+}
+
+func fakeS3WriteXML(w http.ResponseWriter, v interface{}) {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write(data)
+}
+
+func fakeS3WriteError(w http.ResponseWriter, r *http.Request, status int, code, bucket, key string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	if r.Method == "HEAD" {
+		return
+	}
+	data, _ := xml.Marshal(fakeS3Error{
+		Code:       code,
+		Message:    fmt.Sprintf("Injected %d %s", status, code),
+		BucketName: bucket,
+		Key:        key,
+		RequestId:  "FAKE",
+	})
+	w.Write(data)
+}
+
+// testApply plans raw against state and applies the plan the way
+// terraform apply does.  It returns the new state, nil once destroyed.
+func testApply(t *testing.T, r *schema.Resource, state *terraform.InstanceState, raw map[string]interface{}, meta interface{}) *terraform.InstanceState {
+	t.Helper()
+	diff, err := r.Diff(state, testResourceConfig(t, raw), meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff == nil || diff.Empty() {
+		return state
+	}
+	state, err = r.Apply(state, diff, meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return state
+}
+
+// testRefresh reads the resource the way terraform refresh does.
+func testRefresh(t *testing.T, r *schema.Resource, state *terraform.InstanceState, meta interface{}) *terraform.InstanceState {
+	t.Helper()
+	state, err := r.Refresh(state, meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return state
+}
+
+// testPlan refreshes state and returns the plan for raw, nil when there are
+// no changes.
+func testPlan(t *testing.T, r *schema.Resource, state *terraform.InstanceState, raw map[string]interface{}, meta interface{}) *terraform.InstanceDiff {
+	t.Helper()
+	state = testRefresh(t, r, state, meta)
+	diff, err := r.Diff(state, testResourceConfig(t, raw), meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff == nil || diff.Empty() {
+		return nil
+	}
+	return diff
+}
+
+// testCheckNoPlan fails the test when raw still differs from the refreshed
+// state.
+func testCheckNoPlan(t *testing.T, r *schema.Resource, state *terraform.InstanceState, raw map[string]interface{}, meta interface{}) {
+	t.Helper()
+	if diff := testPlan(t, r, state, raw, meta); diff != nil {
+		t.Fatalf("expected an empty plan, got: %#v", diff.Attributes)
+	}
+}
+
+// testDestroy destroys the resource the way terraform destroy does.
+func testDestroy(t *testing.T, r *schema.Resource, state *terraform.InstanceState, meta interface{}) {
+	t.Helper()
+	if _, err := r.Apply(state, &terraform.InstanceDiff{Destroy: true}, meta); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testImport imports id the way terraform import does, followed by the
+// refresh Terraform runs on the imported state.
+func testImport(t *testing.T, r *schema.Resource, id string, meta interface{}) *terraform.InstanceState {
+	t.Helper()
+	data, err := r.Importer.State(r.Data(&terraform.InstanceState{ID: id}), meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 1 {
+		t.Fatalf("expected 1 imported resource, got %d", len(data))
+	}
+	state := testRefresh(t, r, data[0].State(), meta)
+	if state == nil {
+		t.Fatalf("imported resource [%s] disappeared on refresh", id)
+	}
+	return state
+}
+
+// testCheckAttributes fails the test unless state has the given attributes.
+func testCheckAttributes(t *testing.T, state *terraform.InstanceState, attributes map[string]string) {
+	t.Helper()
+	if state == nil {
+		t.Fatal("expected a resource in state, got none")
+	}
+	for k, v := range attributes {
+		if got := state.Attributes[k]; got != v {
+			t.Errorf("expected %s = %q, got %q", k, v, got)
+		}
+	}
+}
+
+func TestFakeS3Faults(t *testing.T) {
+	s := newFakeS3(t)
+	meta := s.meta(t, map[string]interface{}{"request_timeout": "100ms"})
+	r := resourceS3Bucket()
+	raw := map[string]interface{}{"bucket": "faults"}
+	state := testApply(t, r, nil, raw, meta)
+
+	// Throttled and unavailable requests are retried.
+	before := s.count("HEAD /faults")
+	s.inject(fakeFault{method: "HEAD", bucket: "faults", status: http.StatusServiceUnavailable, code: "SlowDown", times: 2})
+	testCheckNoPlan(t, r, state, raw, meta)
+	if n := s.count("HEAD /faults") - before; n != 3 {
+		t.Errorf("expected 3 attempts of the bucket read, got %d", n)
+	}
+
+	// So are requests that time out.
+	s.inject(fakeFault{method: "HEAD", bucket: "faults", delay: 300 * time.Millisecond, times: 1})
+	testCheckNoPlan(t, r, state, raw, meta)
+
+	// A server that keeps failing fails the read after max_retries.
+	s.inject(fakeFault{method: "HEAD", bucket: "faults", status: http.StatusServiceUnavailable, code: "ServiceUnavailable"})
+	if _, err := r.Refresh(state, meta); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Fatalf("expected 503 error, got: %v", err)
+	}
+	s.mu.Lock()
+	s.faults = nil
+	s.mu.Unlock()
+
+	// Denied requests fail right away.
+	before = s.count("HEAD /faults")
+	s.inject(fakeFault{method: "HEAD", bucket: "faults", status: http.StatusForbidden, code: "AccessDenied"})
+	if _, err := r.Refresh(state, meta); err == nil || !strings.Contains(err.Error(), "Access Denied") {
+		t.Fatalf("expected Access Denied error, got: %v", err)
+	}
+	if n := s.count("HEAD /faults") - before; n != 1 {
+		t.Errorf("expected 1 attempt of a denied request, got %d", n)
+	}
+	s.mu.Lock()
+	s.faults = nil
+	s.mu.Unlock()
+
+	// A bucket reported missing is removed from the state.
+	s.inject(fakeFault{method: "HEAD", bucket: "faults", status: http.StatusNotFound, code: "NoSuchBucket", times: 1})
+	if state := testRefresh(t, r, state, meta); state != nil {
+		t.Fatalf("expected the bucket to be removed from state, got: %v", state)
+	}
+}
+
+func TestFakeS3OperationTimeout(t *testing.T) {
+	s := newFakeS3(t)
+	s.putBucket("slow", "")
+	meta := s.meta(t, nil)
+	r := resourceS3Object()
+	raw := map[string]interface{}{
+		"bucket":       "slow",
+		"name":         "object.txt",
+		"content":      "content",
+		"content_type": "text/plain",
+		"timeouts":     []map[string]interface{}{{"create": "200ms"}},
+	}
+
+	// Every attempt stalls longer than the create timeout, so the operation
+	// fails once the timeout expires instead of waiting for the server.
+	s.inject(fakeFault{method: "PUT", bucket: "slow", key: "object.txt", delay: 5 * time.Second})
+	diff, err := r.Diff(nil, testResourceConfig(t, raw), meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if _, err := r.Apply(nil, diff, meta); err == nil {
+		t.Fatal("expected the create to time out")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("create took %s, expected it to stop after its 200ms timeout", elapsed)
+	}
+}
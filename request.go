@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+
+	"github.com/minio/minio-go"
+	"github.com/minio/minio-go/pkg/s3signer"
+	"github.com/minio/minio-go/pkg/s3utils"
+)
+
+// bucketRequest sends a signed request for a bucket subresource that minio-go
+// has no API for, e.g. ?versioning or ?cors.  It reuses the endpoint,
+// credentials, bucket lookup and transport of the minio client.  Error
+// responses are returned as minio.ErrorResponse so they can be classified like
+// any other error.
+func (c *s3Client) bucketRequest(ctx context.Context, operation, method, bucket string, query url.Values, body []byte) ([]byte, error) {
+	return c.bucketRequestWithHeader(ctx, operation, method, bucket, query, nil, body)
+}
+
+// bucketRequestWithHeader is bucketRequest with extra request headers, e.g.
+// x-amz-mfa.
+func (c *s3Client) bucketRequestWithHeader(ctx context.Context, operation, method, bucket string, query url.Values, header http.Header, body []byte) ([]byte, error) {
+	location, err := c.s3Client.GetBucketLocation(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := c.sendBucketRequest(ctx, operation, method, bucket, location, query, header, body)
+	// Like minio, send the request again when the server says the bucket is in
+	// another region than the one it was signed for.
+	if isRegionRedirect(err) {
+		if region := minio.ToErrorResponse(err).Region; region != location {
+			data, err = c.sendBucketRequest(ctx, operation, method, bucket, region, query, header, body)
+		}
+	}
+	return data, err
+}
+
+// sendBucketRequest sends one bucketRequest signed for location.
+func (c *s3Client) sendBucketRequest(ctx context.Context, operation, method, bucket, location string, query url.Values, header http.Header, body []byte) ([]byte, error) {
+	endpoint := url.URL{Scheme: "http", Host: c.endpoint}
+	if c.secure {
+		endpoint.Scheme = "https"
+	}
+	host := endpoint.Host
+	if s3utils.IsAmazonEndpoint(endpoint) && location != "" && location != "us-east-1" {
+		host = "s3." + location + ".amazonaws.com"
+	}
+
+	virtualHost := c.bucket_lookup == "dns" ||
+		(c.bucket_lookup != "path" && s3utils.IsVirtualHostSupported(endpoint, bucket))
+	target := endpoint.Scheme + "://" + host + "/" + bucket + "/"
+	if virtualHost {
+		target = endpoint.Scheme + "://" + bucket + "." + host + "/"
+	}
+	if len(query) > 0 {
+		target += "?" + s3utils.QueryEncode(query)
+	}
+
+	req, err := http.NewRequest(method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.ContentLength = int64(len(body))
+	for name, values := range header {
+		req.Header[http.CanonicalHeaderKey(name)] = values
+	}
+	sum := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(sum[:]))
+	if len(body) > 0 {
+		md5sum := md5.Sum(body)
+		req.Header.Set("Content-Md5", base64.StdEncoding.EncodeToString(md5sum[:]))
+		req.Header.Set("Content-Type", "application/xml")
+	}
+
+	creds, err := c.creds.Get()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case creds.SignerType.IsAnonymous():
+	case creds.SignerType.IsV2():
+		req = s3signer.SignV2(*req, creds.AccessKeyID, creds.SecretAccessKey, virtualHost)
+	default:
+		if location == "" {
+			location = "us-east-1"
+		}
+		req = s3signer.SignV4(*req, creds.AccessKeyID, creds.SecretAccessKey, creds.SessionToken, location)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	c.traceRequest(operation, bucket, req, resp, data)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		errResp := minio.ErrorResponse{}
+		if xml.Unmarshal(data, &errResp) != nil || len(errResp.Code) < 1 {
+			errResp.Code = resp.Status
+			errResp.Message = fmt.Sprintf("%s %s?%s failed: %s", method, bucket, s3utils.QueryEncode(query), resp.Status)
+		}
+		if len(errResp.Region) < 1 {
+			errResp.Region = resp.Header.Get("X-Amz-Bucket-Region")
+		}
+		errResp.StatusCode = resp.StatusCode
+		errResp.BucketName = bucket
+		errResp.Headers = resp.Header
+		return nil, errResp
+	}
+	return data, nil
+}
+
+// traceRequest writes a bucketRequest to the HTTP trace in the same format
+// minio uses for its own requests.
+func (c *s3Client) traceRequest(operation, bucket string, req *http.Request, resp *http.Response, body []byte) {
+	if c.trace == nil {
+		return
+	}
+	if auth := req.Header.Get("Authorization"); auth != "" {
+		req.Header.Set("Authorization", redact(auth))
+	}
+	reqTrace, err := httputil.DumpRequestOut(req, false)
+	if err != nil {
+		return
+	}
+	respTrace, err := httputil.DumpResponse(resp, false)
+	if err != nil {
+		return
+	}
+	w := &taggedTraceWriter{tag: fmt.Sprintf("%s %s", operation, bucket), out: c.trace}
+	fmt.Fprintln(w, traceStart)
+	fmt.Fprint(w, string(reqTrace))
+	fmt.Fprint(w, strings.TrimSuffix(string(respTrace), "\r\n"))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		fmt.Fprint(w, string(body))
+	}
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, traceEnd)
+}
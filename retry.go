@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/minio/minio-go"
+)
+
+// Retries are handled by s3Client.retry according to the provider
+// configuration, so every request is attempted only once by minio.  minio-go
+// has no per-client setting for this, so it is set once when the provider is
+// loaded, before any client sends a request.
+func init() {
+	minio.MaxRetry = 1
+}
+
+// errorClass tells the resources what to do with an error returned by the S3
+// server.
+type errorClass int
+
+const (
+	// errorFatal errors fail the operation right away.
+	errorFatal errorClass = iota
+	// errorRetryable errors are transient: throttling, server errors and
+	// broken connections.
+	errorRetryable
+	// errorGone errors mean the bucket or object no longer exists.
+	errorGone
+)
+
+// S3 error codes for buckets and objects that do not exist.
+var goneS3Codes = map[string]bool{
+	"NoSuchBucket": true,
+	"NoSuchKey":    true,
+	"NoSuchUpload": true,
+}
+
+// S3 error codes the server uses to ask clients to back off and try again.
+var throttledS3Codes = map[string]bool{
+	"SlowDown":             true,
+	"Throttling":           true,
+	"ThrottlingException":  true,
+	"RequestLimitExceeded": true,
+	"RequestThrottled":     true,
+}
+
+// S3 error codes for features the server does not support.  They come with a
+// 501 status but retrying them never helps.
+var unsupportedS3Codes = map[string]bool{
+	"NotImplemented":       true,
+	"XNotImplemented":      true,
+	"MethodNotAllowed":     true,
+	"UnsupportedOperation": true,
+}
+
+// S3 error codes for requests signed for the wrong region.  The response names
+// the region of the bucket, which minio remembers for the next attempt.
+var regionRedirectS3Codes = map[string]bool{
+	"AuthorizationHeaderMalformed": true,
+	"InvalidRegion":                true,
+}
+
+// S3 error codes for transient server side failures.
+var transientS3Codes = map[string]bool{
+	"InternalError":      true,
+	"ServiceUnavailable": true,
+	"RequestTimeout":     true,
+}
+
+func classifyError(err error) errorClass {
+	if err == nil {
+		return errorFatal
+	}
+	resp := minio.ToErrorResponse(err)
+	if goneS3Codes[resp.Code] {
+		return errorGone
+	}
+	if unsupportedS3Codes[resp.Code] || resp.StatusCode == http.StatusNotImplemented {
+		return errorFatal
+	}
+	if isThrottled(err) || isRegionRedirect(err) || transientS3Codes[resp.Code] || resp.StatusCode >= http.StatusInternalServerError {
+		return errorRetryable
+	}
+	if len(resp.Code) > 0 {
+		return errorFatal
+	}
+	if isConnectionError(err) {
+		return errorRetryable
+	}
+	return errorFatal
+}
+
+// isGone reports whether err means the bucket or object does not exist.
+func isGone(err error) bool {
+	return classifyError(err) == errorGone
+}
+
+func isThrottled(err error) bool {
+	resp := minio.ToErrorResponse(err)
+	return throttledS3Codes[resp.Code] || resp.StatusCode == http.StatusTooManyRequests
+}
+
+// isRegionRedirect reports whether err asks for the request to be sent again,
+// signed for the region it names.
+func isRegionRedirect(err error) bool {
+	resp := minio.ToErrorResponse(err)
+	return regionRedirectS3Codes[resp.Code] && len(resp.Region) > 0
+}
+
+// isConnectionError reports whether err is a timed out, reset or refused
+// connection, which is worth another attempt.  Certificate, TLS and DNS
+// lookup failures are not: the next attempt fails the same way.
+func isConnectionError(err error) bool {
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+	if opErr, ok := err.(*net.OpError); ok {
+		if opErr.Timeout() {
+			return true
+		}
+		err = opErr.Err
+	}
+	if sysErr, ok := err.(*os.SyscallError); ok {
+		err = sysErr.Err
+	}
+
+	switch e := err.(type) {
+	case x509.UnknownAuthorityError, x509.CertificateInvalidError, x509.HostnameError,
+		x509.SystemRootsError, x509.ConstraintViolationError, x509.UnhandledCriticalExtension,
+		*tls.CertificateVerificationError, tls.RecordHeaderError:
+		return false
+	case *net.DNSError:
+		return e.IsTimeout || e.IsTemporary
+	case syscall.Errno:
+		return e == syscall.ECONNRESET || e == syscall.ECONNREFUSED || e == syscall.EPIPE
+	case net.Error:
+		return e.Timeout()
+	}
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "connection reset") ||
+		strings.Contains(msg, "broken pipe") ||
+		strings.Contains(msg, "connection refused") ||
+		strings.Contains(msg, "transport connection broken")
+}
+
+// retry runs fn until it succeeds, fails with an error that is not worth
+// retrying, max_retries is exhausted or ctx is done.  Operations that are not
+// idempotent are only retried when the server throttled them or redirected
+// them to another region, since then it is known the request was not carried
+// out.
+func (c *s3Client) retry(ctx context.Context, operation string, idempotent bool, fn func() error) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			if err == nil {
+				err = ctxErr
+			}
+			return err
+		}
+		err = fn()
+		if err == nil || ctx.Err() != nil || classifyError(err) != errorRetryable {
+			return err
+		}
+		if !idempotent && !isThrottled(err) && !isRegionRedirect(err) {
+			return err
+		}
+		if attempt >= c.max_retries {
+			log.Printf("[WARN] %s failed after %d retries.  Error: %v", operation, attempt, err)
+			return err
+		}
+		wait := c.backoff(attempt)
+		log.Printf("[WARN] %s failed, retrying in %s (%d/%d).  Error: %v", operation, wait, attempt+1, c.max_retries, err)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// backoff returns an exponential backoff with full jitter between
+// retry_min_backoff and retry_max_backoff.
+func (c *s3Client) backoff(attempt int) time.Duration {
+	wait := c.retry_max_backoff
+	if attempt < 32 {
+		if exp := c.retry_min_backoff * time.Duration(1<<uint(attempt)); exp > 0 && exp < wait {
+			wait = exp
+		}
+	}
+	if wait <= c.retry_min_backoff {
+		return c.retry_min_backoff
+	}
+	return c.retry_min_backoff + time.Duration(rand.Int63n(int64(wait-c.retry_min_backoff)))
+}
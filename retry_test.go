@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go"
+)
+
+func TestClassifyError(t *testing.T) {
+	cases := []struct {
+		err   error
+		class errorClass
+	}{
+		{minio.ErrorResponse{Code: "NoSuchBucket", StatusCode: http.StatusNotFound}, errorGone},
+		{minio.ErrorResponse{Code: "NoSuchKey", StatusCode: http.StatusNotFound}, errorGone},
+		{minio.ErrorResponse{Code: "SlowDown", StatusCode: http.StatusServiceUnavailable}, errorRetryable},
+		{minio.ErrorResponse{Code: "InternalError", StatusCode: http.StatusInternalServerError}, errorRetryable},
+		{minio.ErrorResponse{Code: "501 Not Implemented", StatusCode: http.StatusNotImplemented}, errorFatal},
+		{minio.ErrorResponse{Code: "NotImplemented", StatusCode: http.StatusNotImplemented}, errorFatal},
+		{minio.ErrorResponse{Code: "MethodNotAllowed", StatusCode: http.StatusMethodNotAllowed}, errorFatal},
+		{minio.ErrorResponse{Code: "AccessDenied", StatusCode: http.StatusForbidden}, errorFatal},
+		{minio.ErrorResponse{Code: "AuthorizationHeaderMalformed", StatusCode: http.StatusBadRequest, Region: "eu-west-1"}, errorRetryable},
+		{minio.ErrorResponse{Code: "InvalidRegion", StatusCode: http.StatusBadRequest, Region: "eu-west-1"}, errorRetryable},
+		{minio.ErrorResponse{Code: "AuthorizationHeaderMalformed", StatusCode: http.StatusBadRequest}, errorFatal},
+		{io.ErrUnexpectedEOF, errorRetryable},
+		{errors.New("read tcp: connection reset by peer"), errorRetryable},
+		{errors.New("something else"), errorFatal},
+		{testURLError(&net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}), errorRetryable},
+		{testURLError(&net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}), errorRetryable},
+		{testURLError(&net.OpError{Op: "write", Net: "tcp", Err: os.NewSyscallError("write", syscall.EPIPE)}), errorRetryable},
+		{testURLError(context.DeadlineExceeded), errorRetryable},
+		{testURLError(io.ErrUnexpectedEOF), errorRetryable},
+		{testURLError(x509.UnknownAuthorityError{}), errorFatal},
+		{testURLError(x509.HostnameError{Host: "s3.example.com"}), errorFatal},
+		{testURLError(tls.RecordHeaderError{Msg: "first record does not look like a TLS handshake"}), errorFatal},
+		{testURLError(&net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "s3.example.com", IsNotFound: true}}), errorFatal},
+		{testURLError(&net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "server misbehaving", Name: "s3.example.com", IsTemporary: true}}), errorRetryable},
+	}
+	for _, c := range cases {
+		if class := classifyError(c.err); class != c.class {
+			t.Errorf("classifyError(%v) = %d, want %d", c.err, class, c.class)
+		}
+	}
+}
+
+// testURLError wraps err the way http.Client.Do returns it.
+func testURLError(err error) error {
+	return &url.Error{Op: "Get", URL: "https://s3.example.com/my-bucket/", Err: err}
+}
+
+func TestRetryNotImplemented(t *testing.T) {
+	client := &s3Client{max_retries: 5, retry_min_backoff: time.Second, retry_max_backoff: 30 * time.Second}
+	attempts := 0
+	start := time.Now()
+	err := client.retry(context.Background(), "test", true, func() error {
+		attempts++
+		return minio.ErrorResponse{Code: "NotImplemented", StatusCode: http.StatusNotImplemented}
+	})
+	if !isNotImplemented(err) {
+		t.Fatalf("expected NotImplemented error, got: %v", err)
+	}
+	if attempts != 1 {
+		t.Fatalf("expected 1 attempt, got %d", attempts)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("retry waited %s for an unsupported request", elapsed)
+	}
+}
+
+func TestRetryThrottled(t *testing.T) {
+	client := &s3Client{max_retries: 3, retry_min_backoff: time.Millisecond, retry_max_backoff: 5 * time.Millisecond}
+	attempts := 0
+	err := client.retry(context.Background(), "test", false, func() error {
+		attempts++
+		if attempts < 3 {
+			return minio.ErrorResponse{Code: "SlowDown", StatusCode: http.StatusServiceUnavailable}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 3 {
+		t.Fatalf("expected 3 attempts, got %d", attempts)
+	}
+}
+
+func TestRetryRegionRedirect(t *testing.T) {
+	s := newFakeS3(t)
+	s.putBucket("eu-bucket", "eu-west-1")
+
+	// A denied location lookup makes minio assume us-east-1, so the server
+	// redirects the first upload to eu-west-1.  minio only remembers the
+	// region, the provider sends the upload again.
+	meta := s.meta(t, nil)
+	s.inject(fakeFault{method: "GET", bucket: "eu-bucket", subresource: "location", status: http.StatusForbidden, code: "AccessDenied", times: 1})
+	testApply(t, resourceS3Object(), nil, map[string]interface{}{
+		"bucket":       "eu-bucket",
+		"name":         "object.txt",
+		"content":      "content",
+		"content_type": "text/plain",
+	}, meta)
+	if s.object("eu-bucket", "object.txt") == nil {
+		t.Fatal("expected the object to be uploaded")
+	}
+	if n := s.count("PUT /eu-bucket/object.txt"); n != 2 {
+		t.Fatalf("expected the redirected upload to be sent again, got %d requests", n)
+	}
+
+	// Requests minio has no API for follow the redirect themselves.
+	meta = s.meta(t, nil)
+	s.inject(fakeFault{method: "GET", bucket: "eu-bucket", subresource: "location", status: http.StatusForbidden, code: "AccessDenied", times: 1})
+	testApply(t, resourceS3BucketCors(), nil, map[string]interface{}{
+		"bucket": "eu-bucket",
+		"cors_rule": []interface{}{map[string]interface{}{
+			"allowed_origins": []interface{}{"*"},
+			"allowed_methods": []interface{}{"GET"},
+		}},
+	}, meta)
+	if len(s.subresource("eu-bucket", "cors")) < 1 {
+		t.Fatal("expected the CORS configuration to be set")
+	}
+	if n := s.count("PUT /eu-bucket?cors"); n != 2 {
+		t.Fatalf("expected the redirected request to be sent again, got %d requests", n)
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/pkg/credentials"
+)
+
+// Credentials obtained through AssumeRole are refreshed this long before they
+// expire so a request never goes out with a token that is about to lapse.
+const assumeRoleExpiryWindow = 5 * time.Minute
+
+// assumeRoleProvider exchanges the base credentials for temporary ones through
+// an STS compatible AssumeRole endpoint.  It implements credentials.Provider so
+// the minio client refreshes the temporary credentials on its own once they
+// are about to expire.
+type assumeRoleProvider struct {
+	credentials.Expiry
+
+	base         *credentials.Credentials
+	endpoint     string
+	region       string
+	role_arn     string
+	session_name string
+	external_id  string
+	duration     time.Duration
+	client       *http.Client
+	debug        bool
+}
+
+type assumeRoleResponse struct {
+	Result struct {
+		Credentials struct {
+			AccessKeyId     string    `xml:"AccessKeyId"`
+			SecretAccessKey string    `xml:"SecretAccessKey"`
+			SessionToken    string    `xml:"SessionToken"`
+			Expiration      time.Time `xml:"Expiration"`
+		} `xml:"Credentials"`
+	} `xml:"AssumeRoleResult"`
+}
+
+type stsErrorResponse struct {
+	Error struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	} `xml:"Error"`
+}
+
+func (p *assumeRoleProvider) Retrieve() (credentials.Value, error) {
+	base, err := p.base.Get()
+	if err != nil {
+		return credentials.Value{}, err
+	}
+	if base.SignerType.IsAnonymous() {
+		return credentials.Value{}, errors.New("Unable to assume role.  No base credentials available")
+	}
+
+	form := url.Values{}
+	form.Set("Action", "AssumeRole")
+	form.Set("Version", "2011-06-15")
+	form.Set("RoleArn", p.role_arn)
+	form.Set("RoleSessionName", p.session_name)
+	form.Set("DurationSeconds", strconv.Itoa(int(p.duration.Seconds())))
+	if len(p.external_id) > 0 {
+		form.Set("ExternalId", p.external_id)
+	}
+	body := form.Encode()
+
+	req, err := http.NewRequest("POST", p.endpoint, strings.NewReader(body))
+	if err != nil {
+		return credentials.Value{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	signSTSRequest(req, body, base, p.region)
+
+	if p.debug {
+		log.Printf("[DEBUG] Assuming role [%s] through [%s]", p.role_arn, p.endpoint)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return credentials.Value{}, errors.New(fmt.Sprintf("Unable to assume role [%s].  Error: %v", p.role_arn, err))
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return credentials.Value{}, errors.New(fmt.Sprintf("Unable to assume role [%s].  Error: %v", p.role_arn, err))
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var stsErr stsErrorResponse
+		if xml.Unmarshal(data, &stsErr) == nil && len(stsErr.Error.Code) > 0 {
+			return credentials.Value{}, errors.New(fmt.Sprintf("Unable to assume role [%s].  Error: %s: %s",
+				p.role_arn, stsErr.Error.Code, stsErr.Error.Message))
+		}
+		return credentials.Value{}, errors.New(fmt.Sprintf("Unable to assume role [%s].  Error: %s", p.role_arn, resp.Status))
+	}
+
+	var result assumeRoleResponse
+	if err := xml.Unmarshal(data, &result); err != nil {
+		return credentials.Value{}, errors.New(fmt.Sprintf("Unable to parse AssumeRole response.  Error: %v", err))
+	}
+	creds := result.Result.Credentials
+	registerSecret(creds.SecretAccessKey)
+	registerSecret(creds.SessionToken)
+
+	window := assumeRoleExpiryWindow
+	if p.duration/2 < window {
+		window = p.duration / 2
+	}
+	p.SetExpiration(creds.Expiration, window)
+
+	if p.debug {
+		log.Printf("[DEBUG] Assumed role [%s].  Credentials expire at [%s]", p.role_arn, creds.Expiration)
+	}
+
+	return credentials.Value{
+		AccessKeyID:     creds.AccessKeyId,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+		SignerType:      credentials.SignatureV4,
+	}, nil
+}
+
+// signSTSRequest signs req with AWS Signature Version 4 for the sts service.
+// The s3signer package only knows about the s3 service scope.
+func signSTSRequest(req *http.Request, body string, creds credentials.Value, region string) {
+	t := time.Now().UTC()
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+
+	payload := sha256.Sum256([]byte(body))
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payload[:]))
+	if len(creds.SessionToken) > 0 {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	signed := []string{"content-type", "host", "x-amz-content-sha256", "x-amz-date"}
+	if len(creds.SessionToken) > 0 {
+		signed = append(signed, "x-amz-security-token")
+	}
+	var headers []string
+	for _, h := range signed {
+		value := req.Header.Get(h)
+		if h == "host" {
+			value = req.URL.Host
+		}
+		headers = append(headers, h+":"+strings.TrimSpace(value))
+	}
+
+	path := req.URL.EscapedPath()
+	if len(path) == 0 {
+		path = "/"
+	}
+	canonical := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.RawQuery,
+		strings.Join(headers, "\n") + "\n",
+		strings.Join(signed, ";"),
+		hex.EncodeToString(payload[:]),
+	}, "\n")
+
+	scope := strings.Join([]string{date, region, "sts", "aws4_request"}, "/")
+	canonicalSum := sha256.Sum256([]byte(canonical))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(canonicalSum[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "sts")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, strings.Join(signed, ";"), signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
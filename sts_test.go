@@ -0,0 +1,292 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/pkg/credentials"
+)
+
+// fakeSTS is an STS AssumeRole endpoint that checks the signature of every
+// request against secret_key and answers with expiry as the lifetime of the
+// temporary credentials.
+type fakeSTS struct {
+	*httptest.Server
+	secret_key string
+	expiry     time.Duration
+
+	mu    sync.Mutex
+	calls int
+	form  url.Values
+	token string
+}
+
+func newFakeSTS(t *testing.T, secret_key string, expiry time.Duration) *fakeSTS {
+	s := &fakeSTS{secret_key: secret_key, expiry: expiry}
+	s.Server = httptest.NewServer(s)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// last returns the number of successful calls and the form and session token
+// of the last one.
+func (s *fakeSTS) last() (int, url.Values, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls, s.form, s.token
+}
+
+func (s *fakeSTS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	if err := verifySTSSignature(r, string(body), s.secret_key); err != nil {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprintf(w, `<ErrorResponse><Error><Code>SignatureDoesNotMatch</Code><Message>%v</Message></Error></ErrorResponse>`, err)
+		return
+	}
+	form, _ := url.ParseQuery(string(body))
+
+	s.mu.Lock()
+	s.calls++
+	s.form = form
+	s.token = r.Header.Get("X-Amz-Security-Token")
+	n := s.calls
+	s.mu.Unlock()
+
+	fmt.Fprintf(w, `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleResult>
+    <Credentials>
+      <AccessKeyId>ASIATEMPORARY%d</AccessKeyId>
+      <SecretAccessKey>temporary/secret/%d</SecretAccessKey>
+      <SessionToken>temporary-token-%d</SessionToken>
+      <Expiration>%s</Expiration>
+    </Credentials>
+  </AssumeRoleResult>
+</AssumeRoleResponse>`, n, n, n, time.Now().Add(s.expiry).UTC().Format(time.RFC3339))
+}
+
+// verifySTSSignature checks the AWS Signature Version 4 of an sts request the
+// way the server does: from the headers it received.
+func verifySTSSignature(r *http.Request, body, secret_key string) error {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 ") {
+		return fmt.Errorf("unexpected authorization: %s", auth)
+	}
+	fields := map[string]string{}
+	for _, field := range strings.Split(strings.TrimPrefix(auth, "AWS4-HMAC-SHA256 "), ", ") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("malformed authorization: %s", auth)
+		}
+		fields[kv[0]] = kv[1]
+	}
+	credential := strings.SplitN(fields["Credential"], "/", 2)
+	if len(credential) != 2 {
+		return fmt.Errorf("malformed credential: %s", fields["Credential"])
+	}
+	scope := strings.Split(credential[1], "/")
+	if len(scope) != 4 || scope[2] != "sts" || scope[3] != "aws4_request" {
+		return fmt.Errorf("unexpected scope: %s", credential[1])
+	}
+
+	var headers []string
+	for _, h := range strings.Split(fields["SignedHeaders"], ";") {
+		value := r.Header.Get(h)
+		if h == "host" {
+			value = r.Host
+		}
+		headers = append(headers, h+":"+strings.TrimSpace(value))
+	}
+	payload := sha256Hex(body)
+	if r.Header.Get("X-Amz-Content-Sha256") != payload {
+		return fmt.Errorf("payload hash mismatch")
+	}
+	canonical := strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		r.URL.RawQuery,
+		strings.Join(headers, "\n") + "\n",
+		fields["SignedHeaders"],
+		payload,
+	}, "\n")
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		r.Header.Get("X-Amz-Date"),
+		credential[1],
+		sha256Hex(canonical),
+	}, "\n")
+
+	key := []byte("AWS4" + secret_key)
+	for _, part := range scope {
+		key = hmacSHA256(key, part)
+	}
+	if signature := hex.EncodeToString(hmacSHA256(key, stringToSign)); signature != fields["Signature"] {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+func sha256Hex(data string) string {
+	h := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(h[:])
+}
+
+func TestAssumeRoleProviderRetrieve(t *testing.T) {
+	sts := newFakeSTS(t, "base/secret", time.Hour)
+	provider := &assumeRoleProvider{
+		base:         credentials.NewStaticV4("AKIABASE", "base/secret", "base-token"),
+		endpoint:     sts.URL + "/",
+		region:       "us-east-1",
+		role_arn:     "arn:aws:iam::123456789012:role/terraform",
+		session_name: "terraform",
+		external_id:  "external",
+		duration:     time.Hour,
+		client:       sts.Client(),
+	}
+
+	value, err := provider.Retrieve()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value.AccessKeyID != "ASIATEMPORARY1" || value.SecretAccessKey != "temporary/secret/1" ||
+		value.SessionToken != "temporary-token-1" || !value.SignerType.IsV4() {
+		t.Fatalf("unexpected credentials: %#v", value)
+	}
+	_, form, token := sts.last()
+	if token != "base-token" {
+		t.Errorf("expected the base session token to be sent, got: %q", token)
+	}
+	for k, v := range map[string]string{
+		"Action":          "AssumeRole",
+		"RoleArn":         "arn:aws:iam::123456789012:role/terraform",
+		"RoleSessionName": "terraform",
+		"DurationSeconds": "3600",
+		"ExternalId":      "external",
+	} {
+		if got := form.Get(k); got != v {
+			t.Errorf("expected %s = %q, got %q", k, v, got)
+		}
+	}
+	if provider.IsExpired() {
+		t.Fatal("expected credentials valid for an hour not to be expired")
+	}
+}
+
+func TestAssumeRoleProviderErrors(t *testing.T) {
+	// Credentials the endpoint does not accept fail with its error code.
+	sts := newFakeSTS(t, "other/secret", time.Hour)
+	provider := &assumeRoleProvider{
+		base:     credentials.NewStaticV4("AKIABASE", "base/secret", ""),
+		endpoint: sts.URL + "/",
+		region:   "us-east-1",
+		role_arn: "arn:aws:iam::123456789012:role/terraform",
+		duration: time.Hour,
+		client:   sts.Client(),
+	}
+	_, err := provider.Retrieve()
+	if err == nil || !strings.Contains(err.Error(), "SignatureDoesNotMatch: signature mismatch") {
+		t.Fatalf("expected SignatureDoesNotMatch error, got: %v", err)
+	}
+
+	// Errors without an STS error document report the HTTP status.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	provider.endpoint = server.URL + "/"
+	_, err = provider.Retrieve()
+	if err == nil || !strings.Contains(err.Error(), "503 Service Unavailable") {
+		t.Fatalf("expected 503 error, got: %v", err)
+	}
+
+	// Anonymous base credentials can not assume a role.
+	provider.base = credentials.NewStaticV4("", "", "")
+	if _, err := provider.Retrieve(); err == nil || !strings.Contains(err.Error(), "No base credentials") {
+		t.Fatalf("expected missing base credentials error, got: %v", err)
+	}
+}
+
+func TestAssumeRoleProviderRefresh(t *testing.T) {
+	cases := []struct {
+		duration time.Duration
+		expiry   time.Duration
+		expired  bool
+	}{
+		// Long sessions are refreshed 5 minutes before they expire.
+		{time.Hour, 10 * time.Minute, false},
+		{time.Hour, 4 * time.Minute, true},
+		// Short sessions are refreshed after half their duration.
+		{4 * time.Second, 3 * time.Second, false},
+		{4 * time.Second, time.Second, true},
+	}
+	for _, c := range cases {
+		sts := newFakeSTS(t, "base/secret", c.expiry)
+		creds := credentials.New(&assumeRoleProvider{
+			base:     credentials.NewStaticV4("AKIABASE", "base/secret", ""),
+			endpoint: sts.URL + "/",
+			region:   "us-east-1",
+			role_arn: "arn:aws:iam::123456789012:role/terraform",
+			duration: c.duration,
+			client:   sts.Client(),
+		})
+		if _, err := creds.Get(); err != nil {
+			t.Fatal(err)
+		}
+		value, err := creds.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		calls := 1
+		if c.expired {
+			calls = 2
+		}
+		if n, _, _ := sts.last(); n != calls {
+			t.Errorf("duration %s, expiry %s: expected %d AssumeRole calls, got %d", c.duration, c.expiry, calls, n)
+		}
+		if want := fmt.Sprintf("ASIATEMPORARY%d", calls); value.AccessKeyID != want {
+			t.Errorf("duration %s, expiry %s: expected credentials %s, got %s", c.duration, c.expiry, want, value.AccessKeyID)
+		}
+	}
+}
+
+func TestProviderAssumeRole(t *testing.T) {
+	sts := newFakeSTS(t, "fake/s3/secret/key", time.Hour)
+	s := newFakeS3(t)
+	meta := s.meta(t, map[string]interface{}{
+		"session_token": "base-token",
+		"assume_role": []map[string]interface{}{{
+			"role_arn":     "arn:aws:iam::123456789012:role/terraform",
+			"sts_endpoint": sts.URL + "/",
+		}},
+	})
+	testApply(t, resourceS3Bucket(), nil, map[string]interface{}{"bucket": "assumed"}, meta)
+	if !s.hasBucket("assumed") {
+		t.Fatal("expected the bucket to be created with the temporary credentials")
+	}
+
+	n, form, token := sts.last()
+	if n != 1 {
+		t.Fatalf("expected 1 AssumeRole call, got %d", n)
+	}
+	if token != "base-token" {
+		t.Errorf("expected the session token to be sent, got: %q", token)
+	}
+	for k, v := range map[string]string{
+		"RoleArn":         "arn:aws:iam::123456789012:role/terraform",
+		"RoleSessionName": "terraform",
+		"DurationSeconds": "3600",
+	} {
+		if got := form.Get(k); got != v {
+			t.Errorf("expected %s = %q, got %q", k, v, got)
+		}
+	}
+}
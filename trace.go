@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/minio/minio-go"
+)
+
+// Trace files are rotated once they grow past traceFileMaxSize, keeping
+// traceFileBackups older files next to the current one (trace.log.1, ...).
+const (
+	traceFileMaxSize = 10 * 1024 * 1024
+	traceFileBackups = 5
+
+	traceStart = "---------START-HTTP---------"
+	traceEnd   = "---------END-HTTP---------"
+)
+
+// traceFile is an io.Writer appending to path and rotating it by size.
+type traceFile struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	size int64
+}
+
+func newTraceFile(path string) (*traceFile, error) {
+	t := &traceFile{path: path}
+	if err := t.open(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *traceFile) open() error {
+	file, err := os.OpenFile(t.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Unable to open trace file [%s].  Error: %v", t.path, err))
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return errors.New(fmt.Sprintf("Unable to open trace file [%s].  Error: %v", t.path, err))
+	}
+	t.file = file
+	t.size = info.Size()
+	return nil
+}
+
+func (t *traceFile) rotate() error {
+	if err := t.file.Close(); err != nil {
+		return err
+	}
+	for i := traceFileBackups - 1; i > 0; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", t.path, i), fmt.Sprintf("%s.%d", t.path, i+1))
+	}
+	if err := os.Rename(t.path, t.path+".1"); err != nil {
+		return err
+	}
+	return t.open()
+}
+
+func (t *traceFile) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.size > 0 && t.size+int64(len(p)) > traceFileMaxSize {
+		if err := t.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := t.file.Write(p)
+	t.size += int64(n)
+	return n, err
+}
+
+// logTraceWriter sends HTTP traces to the provider log when no trace file is
+// configured.
+type logTraceWriter struct{}
+
+func (logTraceWriter) Write(p []byte) (int, error) {
+	log.Printf("[DEBUG] HTTP Trace:\n%s", p)
+	return len(p), nil
+}
+
+// taggedTraceWriter collects the trace of a single request, labels it with the
+// resource operation that caused it and writes it out in one piece once the
+// request is complete, so traces of parallel operations do not interleave.
+type taggedTraceWriter struct {
+	mu  sync.Mutex
+	tag string
+	out io.Writer
+	buf bytes.Buffer
+}
+
+func (w *taggedTraceWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf.Write(p)
+	if !strings.Contains(w.buf.String(), traceEnd) {
+		return len(p), nil
+	}
+	trace := strings.Replace(w.buf.String(), traceStart, fmt.Sprintf("%s [%s]", traceStart, w.tag), 1)
+	w.buf.Reset()
+	if _, err := io.WriteString(w.out, redact(trace)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// clientFor returns the minio client to use for one resource operation.  With
+// HTTP tracing enabled every request it sends is tagged with the resource
+// type, operation and ID, e.g. "s3_file.create my_bucket/my_object".  The copy
+// shares the connection pool, credentials and bucket location cache of the
+// provider client.
+func (c *s3Client) clientFor(operation, id string) *minio.Client {
+	if c.trace == nil {
+		return c.s3Client
+	}
+	client := *c.s3Client
+	client.TraceOn(&taggedTraceWriter{
+		tag: fmt.Sprintf("%s %s", operation, id),
+		out: c.trace,
+	})
+	return &client
+}
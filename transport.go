@@ -0,0 +1,143 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// newTransport builds the HTTP transport used by the minio client.  It starts
+// from the same settings as minio.DefaultTransport and layers the TLS, proxy,
+// timeout and connection pool options of the provider configuration on top.
+func (c *Config) newTransport() (*http.Transport, error) {
+	tlsConfig, err := c.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	proxy, err := c.proxyFunc()
+	if err != nil {
+		return nil, err
+	}
+
+	if c.debug {
+		log.Printf("[DEBUG] Connect Timeout: [%s], Request Timeout: [%s], Idle Connection Timeout: [%s], Max Idle Connections Per Host: [%d]",
+			c.connect_timeout, c.request_timeout, c.idle_conn_timeout, c.max_idle_conns_per_host)
+	}
+
+	return &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   c.connect_timeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   c.max_idle_conns_per_host,
+		IdleConnTimeout:       c.idle_conn_timeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		ResponseHeaderTimeout: c.request_timeout,
+		TLSClientConfig:       tlsConfig,
+		// Objects stored with a gzip content-encoding must not be decoded
+		// on the way down.
+		DisableCompression: true,
+	}, nil
+}
+
+func (c *Config) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         c.tls_server_name,
+		InsecureSkipVerify: c.insecure_skip_verify,
+	}
+
+	if c.insecure_skip_verify {
+		log.Println("[WARN] TLS certificate verification is disabled")
+	}
+
+	// CA Bundle
+	if len(c.ca_cert_file) > 0 || len(c.ca_cert_pem) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if len(c.ca_cert_file) > 0 {
+			pem, err := ioutil.ReadFile(c.ca_cert_file)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("Unable to read CA certificate file [%s].  Error: %v", c.ca_cert_file, err))
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, errors.New(fmt.Sprintf("No certificates found in CA certificate file [%s]", c.ca_cert_file))
+			}
+		}
+		if len(c.ca_cert_pem) > 0 {
+			if !pool.AppendCertsFromPEM([]byte(c.ca_cert_pem)) {
+				return nil, errors.New("No certificates found in ca_cert_pem")
+			}
+		}
+		tlsConfig.RootCAs = pool
+		if c.debug {
+			log.Printf("[DEBUG] CA Certificate File: [%s]", c.ca_cert_file)
+		}
+	}
+
+	// Client Certificate
+	if len(c.client_cert) > 0 || len(c.client_key) > 0 {
+		if len(c.client_cert) < 1 || len(c.client_key) < 1 {
+			return nil, errors.New("Both client_cert and client_key must be defined for client certificate authentication")
+		}
+		cert, err := tls.LoadX509KeyPair(c.client_cert, c.client_key)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Unable to load client certificate [%s].  Error: %v", c.client_cert, err))
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+		if c.debug {
+			log.Printf("[DEBUG] Client Certificate: [%s]", c.client_cert)
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// proxyFunc returns the proxy selection used by the transport.  Without an
+// explicit http_proxy the usual HTTP_PROXY/HTTPS_PROXY/NO_PROXY environment
+// variables apply.
+func (c *Config) proxyFunc() (func(*http.Request) (*url.URL, error), error) {
+	if len(c.http_proxy) < 1 {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	proxyURL, err := url.Parse(c.http_proxy)
+	if err != nil || len(proxyURL.Host) < 1 {
+		return nil, errors.New(fmt.Sprintf("Invalid http_proxy [%s]", c.http_proxy))
+	}
+	if c.debug {
+		log.Printf("[DEBUG] HTTP Proxy: [%s], No Proxy: [%s]", proxyURL.Host, c.no_proxy)
+	}
+
+	var exclusions []string
+	for _, host := range strings.Split(c.no_proxy, ",") {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if len(host) > 0 {
+			exclusions = append(exclusions, host)
+		}
+	}
+
+	return func(req *http.Request) (*url.URL, error) {
+		host := strings.ToLower(req.URL.Hostname())
+		for _, exclusion := range exclusions {
+			if exclusion == "*" || host == strings.TrimPrefix(exclusion, ".") ||
+				strings.HasSuffix(host, "."+strings.TrimPrefix(exclusion, ".")) {
+				return nil, nil
+			}
+		}
+		return proxyURL, nil
+	}, nil
+}
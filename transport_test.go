@@ -0,0 +1,315 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// testCert is a certificate and its key, both in PEM as well.
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM string
+	keyPEM  string
+}
+
+func newTestCA(t *testing.T) *testCert {
+	return issueTestCert(t, nil, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	})
+}
+
+// issue returns a certificate signed by ca for the given names and usage.
+func (ca *testCert) issue(t *testing.T, names []string, usage x509.ExtKeyUsage) *testCert {
+	template := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: names[0]},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{usage},
+	}
+	for _, name := range names {
+		if ip := net.ParseIP(name); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, name)
+		}
+	}
+	return issueTestCert(t, ca, template)
+}
+
+func issueTestCert(t *testing.T, ca *testCert, template *x509.Certificate) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SerialNumber = serial
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+
+	parent, signer := template, key
+	if ca != nil {
+		parent, signer = ca.cert, ca.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		keyPEM:  string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})),
+	}
+}
+
+// newFakeS3TLS starts a fake S3 server that serves cert over TLS and, when
+// clientCA is set, requires client certificates signed by it.
+func newFakeS3TLS(t *testing.T, cert *testCert, clientCA *testCert) *fakeS3 {
+	pair, err := tls.X509KeyPair([]byte(cert.certPEM), []byte(cert.keyPEM))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeS3{buckets: map[string]*fakeBucket{}}
+	s.Server = httptest.NewUnstartedServer(s)
+	s.TLS = &tls.Config{Certificates: []tls.Certificate{pair}}
+	if clientCA != nil {
+		pool := x509.NewCertPool()
+		pool.AddCert(clientCA.cert)
+		s.TLS.ClientCAs = pool
+		s.TLS.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	// Handshake failures are expected and only clutter the test output.
+	s.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	s.StartTLS()
+	t.Cleanup(s.Close)
+	return s
+}
+
+// testTLSRequest configures the provider against s with the given TLS
+// arguments and sends a request through it.
+func testTLSRequest(t *testing.T, s *fakeS3, tls map[string]interface{}) error {
+	meta := s.meta(t, tls)
+	_, err := meta.(*s3Client).s3Client.BucketExists("tls")
+	return err
+}
+
+func TestTLSServerVerification(t *testing.T) {
+	ca := newTestCA(t)
+	s := newFakeS3TLS(t, ca.issue(t, []string{"127.0.0.1"}, x509.ExtKeyUsageServerAuth), nil)
+
+	// An unknown CA is rejected, and not retried.
+	err := testTLSRequest(t, s, nil)
+	if err == nil || !strings.Contains(err.Error(), "certificate") {
+		t.Fatalf("expected a certificate error, got: %v", err)
+	}
+	if class := classifyError(err); class != errorFatal {
+		t.Fatalf("expected the certificate error to be fatal, got: %d", class)
+	}
+
+	// The CA can be given inline or as a file.
+	if err := testTLSRequest(t, s, map[string]interface{}{"ca_cert_pem": ca.certPEM}); err != nil {
+		t.Fatalf("ca_cert_pem: %v", err)
+	}
+	if err := testTLSRequest(t, s, map[string]interface{}{"ca_cert_file": testFile(t, "ca.pem", ca.certPEM)}); err != nil {
+		t.Fatalf("ca_cert_file: %v", err)
+	}
+
+	// Or verification is skipped altogether.
+	if err := testTLSRequest(t, s, map[string]interface{}{"insecure_skip_verify": true}); err != nil {
+		t.Fatalf("insecure_skip_verify: %v", err)
+	}
+}
+
+func TestTLSServerName(t *testing.T) {
+	ca := newTestCA(t)
+	s := newFakeS3TLS(t, ca.issue(t, []string{"s3.internal"}, x509.ExtKeyUsageServerAuth), nil)
+
+	// The certificate is not valid for the address the server is reached at.
+	if err := testTLSRequest(t, s, map[string]interface{}{"ca_cert_pem": ca.certPEM}); err == nil || !strings.Contains(err.Error(), "certificate") {
+		t.Fatalf("expected a certificate error, got: %v", err)
+	}
+	if err := testTLSRequest(t, s, map[string]interface{}{"ca_cert_pem": ca.certPEM, "tls_server_name": "s3.internal"}); err != nil {
+		t.Fatalf("tls_server_name: %v", err)
+	}
+}
+
+func TestTLSClientCertificate(t *testing.T) {
+	ca := newTestCA(t)
+	s := newFakeS3TLS(t, ca.issue(t, []string{"127.0.0.1"}, x509.ExtKeyUsageServerAuth), ca)
+	client := ca.issue(t, []string{"terraform"}, x509.ExtKeyUsageClientAuth)
+
+	// The server requires a client certificate.
+	if err := testTLSRequest(t, s, map[string]interface{}{"ca_cert_pem": ca.certPEM}); err == nil || !strings.Contains(err.Error(), "certificate required") {
+		t.Fatalf("expected the handshake to fail without a client certificate, got: %v", err)
+	}
+	err := testTLSRequest(t, s, map[string]interface{}{
+		"ca_cert_pem": ca.certPEM,
+		"client_cert": testFile(t, "client.pem", client.certPEM),
+		"client_key":  testFile(t, "client.key", client.keyPEM),
+	})
+	if err != nil {
+		t.Fatalf("client_cert: %v", err)
+	}
+
+	// A certificate from another CA is rejected.
+	other := newTestCA(t).issue(t, []string{"terraform"}, x509.ExtKeyUsageClientAuth)
+	err = testTLSRequest(t, s, map[string]interface{}{
+		"ca_cert_pem": ca.certPEM,
+		"client_cert": testFile(t, "other.pem", other.certPEM),
+		"client_key":  testFile(t, "other.key", other.keyPEM),
+	})
+	if err == nil || !strings.Contains(err.Error(), "unknown certificate authority") {
+		t.Fatalf("expected the handshake to fail with a certificate from another CA, got: %v", err)
+	}
+}
+
+func TestTLSConfigErrors(t *testing.T) {
+	ca := newTestCA(t)
+	client := ca.issue(t, []string{"terraform"}, x509.ExtKeyUsageClientAuth)
+	cases := map[string]map[string]interface{}{
+		"Both client_cert and client_key":  {"client_cert": testFile(t, "client.pem", client.certPEM)},
+		"Unable to load client":            {"client_cert": testFile(t, "key.pem", client.keyPEM), "client_key": testFile(t, "client.key", client.keyPEM)},
+		"No certificates found in ca_cert": {"ca_cert_pem": "not a certificate"},
+		"Unable to read CA certificate":    {"ca_cert_file": filepath.Join(t.TempDir(), "missing.pem")},
+	}
+	for want, tls := range cases {
+		raw := map[string]interface{}{"s3_server": "https://127.0.0.1:9000", "s3_access_key": "access", "s3_secret_key": "secret"}
+		for k, v := range tls {
+			raw[k] = v
+		}
+		err := Provider().(*schema.Provider).Configure(testResourceConfig(t, raw))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q error, got: %v", want, err)
+		}
+	}
+}
+
+func TestProxy(t *testing.T) {
+	s := newFakeS3(t)
+	var mu sync.Mutex
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		proxied = append(proxied, r.Method+" "+r.URL.String())
+		mu.Unlock()
+		s.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+	count := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(proxied)
+	}
+
+	// Requests go through the proxy, addressed to the S3 Server.
+	meta := s.meta(t, map[string]interface{}{"http_proxy": proxy.URL})
+	testApply(t, resourceS3Bucket(), nil, map[string]interface{}{"bucket": "proxied"}, meta)
+	if !s.hasBucket("proxied") || count() < 1 {
+		t.Fatal("expected the bucket to be created through the proxy")
+	}
+	mu.Lock()
+	for _, request := range proxied {
+		if !strings.Contains(request, "://"+s.Listener.Addr().String()+"/proxied") {
+			t.Errorf("expected a request for the S3 Server, got: %s", request)
+		}
+	}
+	mu.Unlock()
+
+	// Except for the hosts listed in no_proxy.
+	before := count()
+	meta = s.meta(t, map[string]interface{}{"http_proxy": proxy.URL, "no_proxy": "example.com, 127.0.0.1"})
+	testApply(t, resourceS3Bucket(), nil, map[string]interface{}{"bucket": "direct"}, meta)
+	if !s.hasBucket("direct") {
+		t.Fatal("expected the bucket to be created")
+	}
+	if n := count() - before; n != 0 {
+		t.Fatalf("expected no_proxy to bypass the proxy, got %d proxied requests", n)
+	}
+
+	err := Provider().(*schema.Provider).Configure(testResourceConfig(t, map[string]interface{}{
+		"s3_server":  s.URL,
+		"http_proxy": "not a proxy",
+	}))
+	if err == nil || !strings.Contains(err.Error(), "Invalid http_proxy") {
+		t.Fatalf("expected an invalid proxy error, got: %v", err)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	s := newFakeS3(t)
+	s.putBucket("slow", "")
+	s.inject(fakeFault{method: "HEAD", bucket: "slow", delay: 500 * time.Millisecond})
+
+	// A server that does not answer in time fails the request.
+	client := s.meta(t, map[string]interface{}{"request_timeout": "100ms"}).(*s3Client).s3Client
+	start := time.Now()
+	if _, err := client.BucketExists("slow"); err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Fatalf("expected a timeout error, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= 500*time.Millisecond {
+		t.Fatalf("expected the request to stop after 100ms, took %s", elapsed)
+	}
+
+	// By default it is waited for.
+	client = s.meta(t, nil).(*s3Client).s3Client
+	if found, err := client.BucketExists("slow"); err != nil || !found {
+		t.Fatalf("expected the slow bucket to be found, got: %v", err)
+	}
+}
+
+func TestTransportSettings(t *testing.T) {
+	config := &Config{
+		connect_timeout:         5 * time.Second,
+		request_timeout:         10 * time.Second,
+		idle_conn_timeout:       time.Minute,
+		max_idle_conns_per_host: 7,
+	}
+	transport, err := config.newTransport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transport.ResponseHeaderTimeout != 10*time.Second || transport.IdleConnTimeout != time.Minute ||
+		transport.MaxIdleConnsPerHost != 7 {
+		t.Fatalf("expected the configured timeouts and pool size, got: %s, %s, %d",
+			transport.ResponseHeaderTimeout, transport.IdleConnTimeout, transport.MaxIdleConnsPerHost)
+	}
+
+	cases := map[string]int{"30s": 0, "1m30s": 0, "0s": 0, "30": 1, "soon": 1, "-1s": 1}
+	for value, n := range cases {
+		if _, errs := validateDuration(value, "connect_timeout"); len(errs) != n {
+			t.Errorf("validateDuration(%q): expected %d errors, got: %v", value, n, errs)
+		}
+	}
+}